@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AntonPriyma/db_forum/models"
+	"github.com/AntonPriyma/db_forum/repository"
+	log "github.com/sirupsen/logrus"
+)
+
+const adminUsage = "usage: main [flags] admin grant | revoke <nickname>"
+
+// runAdmin обработчик подкоманды admin, через HTTP администратора назначить нельзя
+func runAdmin(roles repository.RolesRepository, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf(adminUsage)
+	}
+
+	var admin bool
+	switch args[0] {
+	case "grant":
+		admin = true
+	case "revoke":
+	default:
+		return fmt.Errorf("unknown admin command %q, %s", args[0], adminUsage)
+	}
+
+	err := roles.SetAdmin(context.Background(), args[1], admin)
+	if err == models.UserNotFound {
+		return fmt.Errorf("user %q not found", args[1])
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("admin %s: %s", args[0], args[1])
+	return nil
+}
@@ -0,0 +1,371 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix префикс переменных окружения, например DB_FORUM_DB_HOST
+const EnvPrefix = "DB_FORUM_"
+
+// Хранилища данных
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+// Что делать с ответами при удалении поста
+const (
+	// DeleteReparent ответы поднимаются на уровень удалённого поста
+	DeleteReparent = "reparent"
+	// DeleteTombstone пост с ответами остаётся заглушкой "[deleted]", пост без ответов удаляется
+	DeleteTombstone = "tombstone"
+	// DeleteCascade пост удаляется вместе со всем поддеревом
+	DeleteCascade = "cascade"
+)
+
+// Database параметры подключения к postgres и пула соединений
+type Database struct {
+	Host           string        `yaml:"host"`
+	Port           uint16        `yaml:"port"`
+	User           string        `yaml:"user"`
+	Password       string        `yaml:"password"`
+	Name           string        `yaml:"name"`
+	MaxConnections int           `yaml:"max_connections"`
+	AcquireTimeout time.Duration `yaml:"acquire_timeout"`
+}
+
+// Config конфигурация сервиса.
+// Источники по возрастанию приоритета: значения по умолчанию, yaml файл,
+// переменные окружения, флаги командной строки.
+type Config struct {
+	// Storage postgres или memory, memory для тестов и локальных демо без базы
+	Storage         string        `yaml:"storage"`
+	Database        Database      `yaml:"database"`
+	Listen          string        `yaml:"listen"`
+	LogLevel        string        `yaml:"log_level"`
+	LogFormat       string        `yaml:"log_format"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	ReadyTimeout    time.Duration `yaml:"ready_timeout"`
+	// RequestTimeout ограничение на обработку запроса, вместе с ним отменяются запросы в базу.
+	// RouteTimeouts переопределяет его для отдельных шаблонов роутов, 0 - без ограничения.
+	RequestTimeout time.Duration            `yaml:"request_timeout"`
+	RouteTimeouts  map[string]time.Duration `yaml:"route_timeouts"`
+	// DeleteChildren судьба ответов при DELETE /post/{id}: reparent, tombstone или cascade
+	DeleteChildren string `yaml:"delete_children"`
+	Auth           Auth   `yaml:"auth"`
+}
+
+// Auth параметры токенов сессий
+type Auth struct {
+	// Secret ключ подписи токенов, обязателен
+	Secret   string        `yaml:"secret"`
+	TokenTTL time.Duration `yaml:"token_ttl"`
+	// Required запрещает анонимные запросы на запись, кроме регистрации и входа, включён по умолчанию.
+	// Без него анонимный клиент пишет от любого имени, как раньше, а вошедший - только от своего.
+	Required bool `yaml:"required"`
+}
+
+// Default конфигурация, совпадающая с окружением из Dockerfile
+func Default() *Config {
+	return &Config{
+		Storage: StoragePostgres,
+		Database: Database{
+			Host:           "localhost",
+			Port:           5432,
+			User:           "docker",
+			Password:       "docker",
+			Name:           "docker",
+			MaxConnections: 20,
+			AcquireTimeout: 0,
+		},
+		Listen:          ":5000",
+		LogLevel:        "info",
+		LogFormat:       "text",
+		ShutdownTimeout: 10 * time.Second,
+		ReadyTimeout:    2 * time.Second,
+		RequestTimeout:  30 * time.Second,
+		DeleteChildren:  DeleteReparent,
+		Auth: Auth{
+			TokenTTL: 24 * time.Hour,
+			Required: true,
+		},
+	}
+}
+
+// option описывает один параметр: имя флага, переменную окружения и куда его записать
+type option struct {
+	flag  string
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+func options() []option {
+	return []option{
+		{"storage", "STORAGE", "storage backend: postgres or memory", func(c *Config, v string) error {
+			c.Storage = v
+			return nil
+		}},
+		{"db-host", "DB_HOST", "postgres host", func(c *Config, v string) error {
+			c.Database.Host = v
+			return nil
+		}},
+		{"db-port", "DB_PORT", "postgres port", func(c *Config, v string) error {
+			port, err := strconv.ParseUint(v, 10, 16)
+			if err != nil {
+				return fmt.Errorf("invalid port %q", v)
+			}
+			c.Database.Port = uint16(port)
+			return nil
+		}},
+		{"db-user", "DB_USER", "postgres user", func(c *Config, v string) error {
+			c.Database.User = v
+			return nil
+		}},
+		{"db-password", "DB_PASSWORD", "postgres password", func(c *Config, v string) error {
+			c.Database.Password = v
+			return nil
+		}},
+		{"db-name", "DB_NAME", "postgres database", func(c *Config, v string) error {
+			c.Database.Name = v
+			return nil
+		}},
+		{"db-max-connections", "DB_MAX_CONNECTIONS", "connection pool size", func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid number %q", v)
+			}
+			c.Database.MaxConnections = n
+			return nil
+		}},
+		{"db-acquire-timeout", "DB_ACQUIRE_TIMEOUT", "pool acquire timeout, e.g. 3s (0 waits forever)", func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", v)
+			}
+			c.Database.AcquireTimeout = d
+			return nil
+		}},
+		{"listen", "LISTEN", "http listen address", func(c *Config, v string) error {
+			c.Listen = v
+			return nil
+		}},
+		{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "how long to wait for in-flight requests on SIGINT/SIGTERM", func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", v)
+			}
+			c.ShutdownTimeout = d
+			return nil
+		}},
+		{"ready-timeout", "READY_TIMEOUT", "time limit for the /readyz database check", func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", v)
+			}
+			c.ReadyTimeout = d
+			return nil
+		}},
+		{"request-timeout", "REQUEST_TIMEOUT", "default per-request deadline, 0 disables", func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", v)
+			}
+			c.RequestTimeout = d
+			return nil
+		}},
+		{"delete-children", "DELETE_CHILDREN", "replies of a deleted post: reparent, tombstone or cascade", func(c *Config, v string) error {
+			c.DeleteChildren = v
+			return nil
+		}},
+		{"auth-secret", "AUTH_SECRET", "session token signing key, required", func(c *Config, v string) error {
+			c.Auth.Secret = v
+			return nil
+		}},
+		{"auth-token-ttl", "AUTH_TOKEN_TTL", "session token lifetime, e.g. 24h", func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", v)
+			}
+			c.Auth.TokenTTL = d
+			return nil
+		}},
+		{"auth-required", "AUTH_REQUIRED", "reject anonymous write requests", func(c *Config, v string) error {
+			required, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid bool %q", v)
+			}
+			c.Auth.Required = required
+			return nil
+		}},
+		{"log-level", "LOG_LEVEL", "log level: debug, info, warning, error", func(c *Config, v string) error {
+			c.LogLevel = v
+			return nil
+		}},
+		{"log-format", "LOG_FORMAT", "log format: text or json", func(c *Config, v string) error {
+			c.LogFormat = v
+			return nil
+		}},
+	}
+}
+
+// Load собирает конфигурацию из args (обычно os.Args[1:]), окружения и файла,
+// путь к которому задаётся флагом -config или переменной DB_FORUM_CONFIG.
+// Возвращает аргументы, оставшиеся после флагов.
+func Load(args []string) (*Config, []string, error) {
+	opts := options()
+
+	fs := flag.NewFlagSet("db_forum", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "path to yaml config file")
+	flagValues := make(map[string]*string, len(opts))
+	for _, o := range opts {
+		flagValues[o.flag] = fs.String(o.flag, "", fmt.Sprintf("%s (env %s%s)", o.usage, EnvPrefix, o.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, o := range opts {
+		value, ok := os.LookupEnv(EnvPrefix + o.env)
+		if !ok {
+			continue
+		}
+		if err := o.set(cfg, value); err != nil {
+			return nil, nil, fmt.Errorf("env %s%s: %s", EnvPrefix, o.env, err)
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, o := range opts {
+			if o.flag != f.Name || flagErr != nil {
+				continue
+			}
+			if err := o.set(cfg, *flagValues[o.flag]); err != nil {
+				flagErr = fmt.Errorf("flag -%s: %s", o.flag, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %s", err)
+	}
+	if err = yaml.UnmarshalStrict(data, c); err != nil {
+		return fmt.Errorf("config file %s: %s", path, err)
+	}
+	return nil
+}
+
+// Validate проверка полей, возвращает все найденные ошибки сразу
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Storage != StoragePostgres && c.Storage != StorageMemory {
+		problems = append(problems, fmt.Sprintf("storage %q must be %s or %s", c.Storage, StoragePostgres, StorageMemory))
+	}
+
+	if c.Database.Host == "" {
+		problems = append(problems, "database host is empty")
+	}
+	if c.Database.Port == 0 {
+		problems = append(problems, "database port must be in range 1-65535")
+	}
+	if c.Database.User == "" {
+		problems = append(problems, "database user is empty")
+	}
+	if c.Database.Name == "" {
+		problems = append(problems, "database name is empty")
+	}
+	if c.Database.MaxConnections < 1 {
+		problems = append(problems, "database max_connections must be positive")
+	}
+	if c.Database.AcquireTimeout < 0 {
+		problems = append(problems, "database acquire_timeout must not be negative")
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		problems = append(problems, fmt.Sprintf("listen address %q: %s", c.Listen, err))
+	}
+	if c.ShutdownTimeout < 0 {
+		problems = append(problems, "shutdown_timeout must not be negative")
+	}
+	if c.ReadyTimeout <= 0 {
+		problems = append(problems, "ready_timeout must be positive")
+	}
+	if c.RequestTimeout < 0 {
+		problems = append(problems, "request_timeout must not be negative")
+	}
+	for route, timeout := range c.RouteTimeouts {
+		if timeout < 0 {
+			problems = append(problems, fmt.Sprintf("route_timeouts %s must not be negative", route))
+		}
+	}
+	switch c.DeleteChildren {
+	case DeleteReparent, DeleteTombstone, DeleteCascade:
+	default:
+		problems = append(problems, fmt.Sprintf("delete_children %q must be %s, %s or %s", c.DeleteChildren, DeleteReparent, DeleteTombstone, DeleteCascade))
+	}
+	if c.Auth.Secret == "" {
+		problems = append(problems, "auth secret is empty")
+	}
+	if c.Auth.TokenTTL <= 0 {
+		problems = append(problems, "auth token_ttl must be positive")
+	}
+	if _, err := logrus.ParseLevel(c.LogLevel); err != nil {
+		problems = append(problems, fmt.Sprintf("log_level: %s", err))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		problems = append(problems, fmt.Sprintf("log_format %q must be text or json", c.LogFormat))
+	}
+
+	if len(problems) != 0 {
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Level уровень логирования, Validate гарантирует что он корректен
+func (c *Config) Level() logrus.Level {
+	level, err := logrus.ParseLevel(c.LogLevel)
+	if err != nil {
+		return logrus.InfoLevel
+	}
+	return level
+}
+
+// Formatter форматтер логов согласно log_format
+func (c *Config) Formatter() logrus.Formatter {
+	if c.LogFormat == "json" {
+		return &logrus.JSONFormatter{}
+	}
+	return &logrus.TextFormatter{}
+}
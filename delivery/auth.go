@@ -0,0 +1,138 @@
+package delivery
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AntonPriyma/db_forum/models"
+	"github.com/AntonPriyma/db_forum/repository"
+	"github.com/AntonPriyma/db_forum/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type AuthHandlers struct {
+	users  repository.UsersRepository
+	tokens *utils.Tokens
+	ttl    time.Duration
+}
+
+func NewAuthHandlers(users repository.UsersRepository, tokens *utils.Tokens, ttl time.Duration) *AuthHandlers {
+	return &AuthHandlers{users: users, tokens: tokens, ttl: ttl}
+}
+
+// Login проверка пароля и выдача токена сессии
+func (h *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+	credentials := &models.Credentials{}
+	if err = credentials.UnmarshalJSON(body); err != nil {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("body", err.Error())))
+		return
+	}
+
+	nickname, hash, err := h.users.GetPasswordHash(r.Context(), credentials.Nickname)
+	switch err {
+	case nil:
+		if hash == "" || bcrypt.CompareHashAndPassword([]byte(hash), []byte(credentials.Password)) != nil {
+			err = models.InvalidCredentials
+		}
+	case models.UserNotFound:
+		err = models.InvalidCredentials
+	}
+
+	switch err {
+	case nil:
+		expires := time.Now().Add(h.ttl).Truncate(time.Second)
+		session := &models.Session{Nickname: nickname, Token: h.tokens.Sign(nickname, expires), Expires: expires}
+		resp, _ := session.MarshalJSON()
+		utils.MakeResponse(w, 200, resp)
+	case models.InvalidCredentials:
+		utils.MakeResponse(w, 401, []byte(utils.MakeErrorUnauthorized(err.Error())))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// hashPassword bcrypt хэш для сохранения в users.password_hash
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// authorize проверяет, что запрос идёт от имени nickname, иначе пишет 403.
+// Анонимный запрос сюда доходит только без auth.required и пропускается, см. Authenticate.
+func authorize(w http.ResponseWriter, r *http.Request, nickname string) bool {
+	caller, ok := utils.Caller(r.Context())
+	if !ok || strings.EqualFold(caller, nickname) {
+		return true
+	}
+	utils.MakeResponse(w, 403, []byte(utils.MakeErrorForbidden(nickname)))
+	return false
+}
+
+// Access проверки ролей вызывающего для ручек модерации и удаления.
+// В отличие от authorize, анонимный запрос здесь не проходит и при выключенном auth.required.
+type Access struct {
+	roles repository.RolesRepository
+}
+
+func NewAccess(roles repository.RolesRepository) *Access {
+	return &Access{roles: roles}
+}
+
+// permit пропускает администратора, пользователей из allowed и модераторов forum, если он задан,
+// анонимному запросу пишет 401, остальным 403
+func (a *Access) permit(w http.ResponseWriter, r *http.Request, forum string, allowed ...string) bool {
+	caller, ok := utils.Caller(r.Context())
+	if !ok {
+		utils.MakeResponse(w, 401, []byte(utils.MakeErrorUnauthorized("authorization required")))
+		return false
+	}
+	for _, nickname := range allowed {
+		if strings.EqualFold(caller, nickname) {
+			return true
+		}
+	}
+
+	admin, err := a.roles.IsAdmin(r.Context(), caller)
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return false
+	}
+	if admin {
+		return true
+	}
+	if forum != "" {
+		moderator, err := a.roles.IsModerator(r.Context(), forum, caller)
+		if err != nil {
+			utils.MakeResponse(w, 500, []byte(err.Error()))
+			return false
+		}
+		if moderator {
+			return true
+		}
+	}
+
+	utils.MakeResponse(w, 403, []byte(utils.MakeErrorAccess(caller)))
+	return false
+}
+
+// permitOwner пропускает владельца форума slug и администратора, на отсутствующий форум пишет 404
+func (a *Access) permitOwner(w http.ResponseWriter, r *http.Request, forums repository.ForumRepository, slug string) bool {
+	forum, err := forums.GetForumBySlug(r.Context(), slug)
+	switch err {
+	case nil:
+		return a.permit(w, r, "", forum.Owner)
+	case models.ForumNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorForum(slug)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+	return false
+}
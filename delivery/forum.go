@@ -8,23 +8,29 @@ import (
 	"github.com/gorilla/mux"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type ForumHandlers struct {
 	forums repository.ForumRepository
 	users repository.UsersRepository
+	access *Access
 }
 
-func NewForumHandlers(forums repository.ForumRepository, users repository.UsersRepository) *ForumHandlers {
-	return &ForumHandlers{forums: forums, users: users}
+func NewForumHandlers(forums repository.ForumRepository, users repository.UsersRepository, access *Access) *ForumHandlers {
+	return &ForumHandlers{forums: forums, users: users, access: access}
 }
 
-// GetForum получение информации о форуме
+// GetForum получение информации о форуме вместе с цепочкой предков
 func(h *ForumHandlers) GetForum(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	slug := params["slug"]
 
-	result, err := h.forums.GetForumBySlug(slug)
+	result, err := h.forums.GetForumBySlug(r.Context(), slug)
+	if err == nil && result.Parent != "" {
+		result.Breadcrumbs, err = h.forums.GetBreadcrumbs(r.Context(), result.Slug)
+	}
 
 	switch err {
 	case nil:
@@ -52,8 +58,16 @@ func(h *ForumHandlers) CreateForum(w http.ResponseWriter, r *http.Request) {
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 		return
 	}
+	// внешний ключ parent пропустит ссылку на самого себя, а предков ищут рекурсивно
+	if forum.Parent != "" && strings.EqualFold(forum.Parent, forum.Slug) {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("parent", forum.Parent)))
+		return
+	}
+	if !authorize(w, r, forum.Owner) {
+		return
+	}
 
-	result, err := h.forums.Create(forum)
+	result, err := h.forums.Create(r.Context(), forum)
 
 	switch err {
 	case nil:
@@ -61,6 +75,8 @@ func(h *ForumHandlers) CreateForum(w http.ResponseWriter, r *http.Request) {
 		utils.MakeResponse(w, 201, resp)
 	case models.UserNotFound:
 		utils.MakeResponse(w, 404, []byte(utils.MakeErrorUser(forum.Owner)))
+	case models.ForumParentNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorForum(forum.Parent)))
 	case models.ForumIsExist:
 		resp, _ := result.MarshalJSON()
 		utils.MakeResponse(w, 409, resp)
@@ -69,6 +85,63 @@ func(h *ForumHandlers) CreateForum(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetForums список всех форумов, sort по slug, title, threads или posts
+func(h *ForumHandlers) GetForums(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	var limit, since, sort, desc string
+	if limit = queryParams.Get("limit"); limit == "" {
+		limit = "1"
+	}
+	since = queryParams.Get("since")
+	if sort = queryParams.Get("sort"); sort == "" {
+		sort = models.ForumSortSlug
+	}
+	if desc = queryParams.Get("desc"); desc == "" {
+		desc = "false"
+	}
+
+	if n, err := strconv.Atoi(limit); err != nil || n < 0 {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("limit", limit)))
+		return
+	}
+	if !models.IsForumSort(sort) {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("sort", sort)))
+		return
+	}
+	if desc != "true" && desc != "false" {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("desc", desc)))
+		return
+	}
+
+	result, err := h.forums.GetForums(r.Context(), limit, since, sort, desc)
+
+	switch err {
+	case nil:
+		resp, _ := swag.WriteJSON(result)
+		utils.MakeResponse(w, 200, resp)
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// GetForumChildren прямые подфорумы
+func(h *ForumHandlers) GetForumChildren(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	slug := params["slug"]
+
+	result, err := h.forums.GetChildren(r.Context(), slug)
+
+	switch err {
+	case nil:
+		resp, _ := swag.WriteJSON(result)
+		utils.MakeResponse(w, 200, resp)
+	case models.ForumNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorForum(slug)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
 func(h *ForumHandlers) GetForumUsers(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	slug := params["slug"]
@@ -85,7 +158,7 @@ func(h *ForumHandlers) GetForumUsers(w http.ResponseWriter, r *http.Request) {
 		desc = "false"
 	}
 
-	result, err := h.forums.GetForumUsersDB(slug, limit, since, desc)
+	result, err := h.forums.GetForumUsersDB(r.Context(), slug, limit, since, desc)
 
 	switch err {
 	case nil:
@@ -97,3 +170,74 @@ func(h *ForumHandlers) GetForumUsers(w http.ResponseWriter, r *http.Request) {
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 	}
 }
+
+// UpdateForum изменение названия, владельца и слага форума
+func(h *ForumHandlers) UpdateForum(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	slug := params["slug"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+	update := &models.ForumUpdate{}
+	err = update.UnmarshalJSON(body)
+
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+	if !h.access.permitOwner(w, r, h.forums, slug) {
+		return
+	}
+
+	result, err := h.forums.Update(r.Context(), slug, update)
+
+	switch err {
+	case nil:
+		resp, _ := result.MarshalJSON()
+		utils.MakeResponse(w, 200, resp)
+	case models.ForumNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorForum(slug)))
+	case models.UserNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorUser(update.Owner)))
+	case models.ForumIsExist:
+		resp, _ := result.MarshalJSON()
+		utils.MakeResponse(w, 409, resp)
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// DeleteForum удаление форума со всеми тредами и постами, при archive=true форум только архивируется
+func(h *ForumHandlers) DeleteForum(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	slug := params["slug"]
+	var archive string
+	if archive = r.URL.Query().Get("archive"); archive == "" {
+		archive = "false"
+	}
+	if archive != "true" && archive != "false" {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("archive", archive)))
+		return
+	}
+	if !h.access.permitOwner(w, r, h.forums, slug) {
+		return
+	}
+
+	result, err := h.forums.Delete(r.Context(), slug, archive == "true")
+
+	switch err {
+	case nil:
+		resp, _ := result.MarshalJSON()
+		utils.MakeResponse(w, 200, resp)
+	case models.ForumNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorForum(slug)))
+	case models.ForumHasChildren:
+		utils.MakeResponse(w, 409, []byte(utils.MakeErrorForumChildren(slug)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
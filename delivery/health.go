@@ -0,0 +1,40 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/AntonPriyma/db_forum/repository"
+	"github.com/AntonPriyma/db_forum/utils"
+	"github.com/go-openapi/swag"
+)
+
+// HealthHandlers пробы для оркестратора, в отличие от /service/status не считают таблицы
+type HealthHandlers struct {
+	service repository.ServiceRepository
+	timeout time.Duration
+}
+
+func NewHealthHandlers(service repository.ServiceRepository, timeout time.Duration) *HealthHandlers {
+	return &HealthHandlers{service: service, timeout: timeout}
+}
+
+// Live процесс жив и обрабатывает запросы, в базу не ходит
+func(h *HealthHandlers) Live(w http.ResponseWriter, r *http.Request) {
+	utils.MakeResponse(w, http.StatusOK, []byte(`{"status": "ok"}`))
+}
+
+// Ready сервис готов принимать трафик: база доступна и схема актуальна
+func(h *HealthHandlers) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if err := h.service.Ready(ctx); err != nil {
+		resp, _ := swag.WriteJSON(map[string]string{"status": "unavailable", "error": err.Error()})
+		utils.MakeResponse(w, http.StatusServiceUnavailable, resp)
+		return
+	}
+
+	utils.MakeResponse(w, http.StatusOK, []byte(`{"status": "ok"}`))
+}
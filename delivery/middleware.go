@@ -0,0 +1,126 @@
+package delivery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AntonPriyma/db_forum/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader заголовок, через который request id приходит от балансера и уходит клиенту
+const RequestIDHeader = "X-Request-ID"
+
+// RequestLogger присваивает запросу request id (или берёт пришедший),
+// кладёт логгер с ним в контекст, чтобы им писали репозитории,
+// и пишет одну строку на запрос.
+func RequestLogger(logger *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			entry := logger.WithFields(logrus.Fields{
+				"request_id": requestID,
+				"method":     r.Method,
+				"route":      utils.RouteTemplate(r),
+			})
+			recorder := utils.NewResponseRecorder(w)
+
+			next.ServeHTTP(recorder, r.WithContext(utils.WithLogger(r.Context(), entry)))
+
+			entry = entry.WithFields(logrus.Fields{
+				"path":     r.URL.Path,
+				"status":   recorder.Status,
+				"duration": time.Since(start).String(),
+				"bytes":    recorder.Bytes,
+			})
+			if recorder.Status >= http.StatusInternalServerError {
+				entry.Error("request failed")
+				return
+			}
+			entry.Info("request")
+		})
+	}
+}
+
+// Timeout ограничивает время обработки запроса через контекст.
+// Репозитории выполняют запросы с этим контекстом, так что по таймауту
+// или при обрыве соединения клиентом запрос в базе отменяется и соединение возвращается в пул.
+func Timeout(timeout time.Duration, routes map[string]time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := timeout
+			if routeLimit, ok := routes[utils.RouteTemplate(r)]; ok {
+				limit = routeLimit
+			}
+			if limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), limit)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// publicRoutes ручки, на которые можно писать без токена и при auth.required
+var publicRoutes = map[string]bool{
+	"/auth/login":             true,
+	"/user/{nickname}/create": true,
+}
+
+// Authenticate проверяет токен из заголовка Authorization: Bearer <token>
+// и кладёт nickname его владельца в контекст, см. utils.Caller.
+// Невалидный или просроченный токен - 401, запрос без токена проходит анонимным,
+// если required не запрещает анонимную запись.
+func Authenticate(tokens *utils.Tokens, required bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				if required && !isSafeMethod(r.Method) && !publicRoutes[utils.RouteTemplate(r)] {
+					utils.MakeResponse(w, 401, []byte(utils.MakeErrorUnauthorized("authorization required")))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			const prefix = "Bearer "
+			if !strings.HasPrefix(header, prefix) {
+				utils.MakeResponse(w, 401, []byte(utils.MakeErrorUnauthorized("bearer token expected")))
+				return
+			}
+			nickname, err := tokens.Parse(strings.TrimPrefix(header, prefix), time.Now())
+			if err != nil {
+				utils.MakeResponse(w, 401, []byte(utils.MakeErrorUnauthorized(err.Error())))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(utils.WithCaller(r.Context(), nickname)))
+		})
+	}
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
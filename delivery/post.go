@@ -16,10 +16,30 @@ import (
 type PostHandlers struct {
 	posts repository.PostRepository
 	users repository.UsersRepository
+	// deleteChildren что делать с ответами удаляемого поста, см. config.DeleteChildren
+	deleteChildren string
+	access         *Access
 }
 
-func NewPostHandlers(posts repository.PostRepository, users repository.UsersRepository) *PostHandlers {
-	return &PostHandlers{posts: posts, users: users}
+func NewPostHandlers(posts repository.PostRepository, users repository.UsersRepository, deleteChildren string, access *Access) *PostHandlers {
+	return &PostHandlers{posts: posts, users: users, deleteChildren: deleteChildren, access: access}
+}
+
+// permitPost пропускает модераторов форума поста и администратора, withAuthor - ещё и автора поста
+func(h *PostHandlers) permitPost(w http.ResponseWriter, r *http.Request, id int, withAuthor bool) bool {
+	result, err := h.posts.GetPostByID(r.Context(), id, nil)
+	switch err {
+	case nil:
+		if withAuthor {
+			return h.access.permit(w, r, result.Post.Forum, result.Post.Author)
+		}
+		return h.access.permit(w, r, result.Post.Forum)
+	case models.PostNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(strconv.Itoa(id))))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+	return false
 }
 
 func(h *PostHandlers) CreatePosts(w http.ResponseWriter, r *http.Request) {
@@ -38,8 +58,13 @@ func(h *PostHandlers) CreatePosts(w http.ResponseWriter, r *http.Request) {
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 		return
 	}
+	for _, post := range *posts {
+		if post != nil && !authorize(w, r, post.Author) {
+			return
+		}
+	}
 
-	result, err := h.posts.Create(posts, param)
+	result, err := h.posts.Create(r.Context(), posts, param)
 
 
 
@@ -53,6 +78,8 @@ func(h *PostHandlers) CreatePosts(w http.ResponseWriter, r *http.Request) {
 		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPostAuthor(param)))
 	case models.PostParentNotFound:
 		utils.MakeResponse(w, 409, []byte(utils.MakeErrorThreadConflict()))
+	case models.ForumArchived:
+		utils.MakeResponse(w, 409, []byte(utils.MakeErrorArchived(param)))
 	default:
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 	}
@@ -77,7 +104,7 @@ func(h *PostHandlers) GetPosts(w http.ResponseWriter, r *http.Request) {
 		desc = "false"
 	}
 	// fmt.Println("limit", limit, "since", since, "sort", sort, "desc", desc)
-	result, err := h.posts.GetThreadPostsDB(param, limit, since, sort, desc)
+	result, err := h.posts.GetThreadPostsDB(r.Context(), param, limit, since, sort, desc)
 
 	// resp, _ := result.MarshalJSON()
 
@@ -105,16 +132,16 @@ func(h *PostHandlers) GetPost(w http.ResponseWriter, r *http.Request) {
 	var related []string
 	related = append(related, strings.Split(relatedQuery, ",")...)
 
-	result, err := h.posts.GetPostByID(id, related)
+	result, err := h.posts.GetPostByID(r.Context(), id, related)
 
 	switch err {
 	case nil:
 		resp, _ := result.MarshalJSON()
 		utils.MakeResponse(w, 200, resp)
 	case models.PostNotFound:
-		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(string(id))))
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(strconv.Itoa(id))))
 	default:
-		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(string(id))))
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(strconv.Itoa(id))))
 	}
 }
 
@@ -139,13 +166,283 @@ func(h *PostHandlers) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 		return
 	}
-	result, err := h.posts.Update(postUpdate, id)
+	if postUpdate.Editor != "" && !authorize(w, r, postUpdate.Editor) {
+		return
+	}
+	// в истории правок модератор записывается под своим именем, а не автора
+	if caller, ok := utils.Caller(r.Context()); ok && postUpdate.Editor == "" {
+		postUpdate.Editor = caller
+	}
+	if !h.permitPost(w, r, id, true) {
+		return
+	}
+	result, err := h.posts.Update(r.Context(), postUpdate, id)
+	switch err {
+	case nil:
+		resp, _ := result.MarshalJSON()
+		utils.MakeResponse(w, 200, resp)
+	case models.PostNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(strconv.Itoa(id))))
+	case models.UserNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorUser(postUpdate.Editor)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// DeletePost удаление поста, ответы обрабатываются согласно deleteChildren
+func(h *PostHandlers) DeletePost(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+	if !h.permitPost(w, r, id, false) {
+		return
+	}
+
+	result, err := h.posts.Delete(r.Context(), id, h.deleteChildren)
+	switch err {
+	case nil:
+		resp, _ := result.MarshalJSON()
+		utils.MakeResponse(w, 200, resp)
+	case models.PostNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(strconv.Itoa(id))))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// HidePost скрытие поста модератором, в дереве вместо него остаётся заглушка
+func(h *PostHandlers) HidePost(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+	hide := &models.Hide{}
+	err = hide.UnmarshalJSON(body)
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+	if hide.Nickname == "" {
+		hide.Nickname, _ = utils.Caller(r.Context())
+	}
+	if !authorize(w, r, hide.Nickname) || !h.permitPost(w, r, id, false) {
+		return
+	}
+
+	result, err := h.posts.Hide(r.Context(), id, hide.Nickname)
 	switch err {
 	case nil:
 		resp, _ := result.MarshalJSON()
 		utils.MakeResponse(w, 200, resp)
 	case models.PostNotFound:
-		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(string(id))))
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(strconv.Itoa(id))))
+	case models.UserNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorUser(hide.Nickname)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// RestorePost возвращает скрытый пост
+func(h *PostHandlers) RestorePost(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+	if !h.permitPost(w, r, id, false) {
+		return
+	}
+
+	result, err := h.posts.Restore(r.Context(), id)
+	switch err {
+	case nil:
+		resp, _ := result.MarshalJSON()
+		utils.MakeResponse(w, 200, resp)
+	case models.PostNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(strconv.Itoa(id))))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// VotePost голос за пост, как Vote у треда. Без nickname голосует владелец токена
+func(h *PostHandlers) VotePost(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+	vote := &models.Vote{}
+	err = vote.UnmarshalJSON(body)
+	if err != nil {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("body", err.Error())))
+		return
+	}
+	if vote.Nickname == "" {
+		vote.Nickname, _ = utils.Caller(r.Context())
+	}
+	if !vote.IsValid() {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("voice", strconv.Itoa(vote.Voice))))
+		return
+	}
+	if !authorize(w, r, vote.Nickname) {
+		return
+	}
+
+	result, err := h.posts.VotePost(r.Context(), id, vote)
+	switch err {
+	case nil:
+		resp, _ := result.MarshalJSON()
+		utils.MakeResponse(w, 200, resp)
+	case models.PostNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(strconv.Itoa(id))))
+	case models.UserNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorUser(vote.Nickname)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// GetPostHistory все ревизии поста, начиная с исходного текста
+func(h *PostHandlers) GetPostHistory(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+
+	result, err := h.posts.GetHistory(r.Context(), id)
+	switch err {
+	case nil:
+		resp, _ := swag.WriteJSON(result)
+		utils.MakeResponse(w, 200, resp)
+	case models.PostNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(strconv.Itoa(id))))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// GetPostDiff пословная разница между ревизиями from и to.
+// По умолчанию сравнивается исходный текст с последней ревизией.
+func(h *PostHandlers) GetPostDiff(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+
+	history, err := h.posts.GetHistory(r.Context(), id)
+	switch err {
+	case nil:
+	case models.PostNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorPost(strconv.Itoa(id))))
+		return
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+	revisions := *history
+
+	queryParams := r.URL.Query()
+	from, to := revisions[0].Revision, revisions[len(revisions)-1].Revision
+	for name, value := range map[string]*int32{"from": &from, "to": &to} {
+		raw := queryParams.Get(name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil {
+			utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam(name, raw)))
+			return
+		}
+		*value = int32(n)
+	}
+
+	diff := &models.PostDiff{Post: int64(id), From: from, To: to}
+	var fromRevision, toRevision *models.PostRevision
+	for _, revision := range revisions {
+		if revision.Revision == from {
+			fromRevision = revision
+		}
+		if revision.Revision == to {
+			toRevision = revision
+		}
+	}
+	if fromRevision == nil {
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorRevision(strconv.Itoa(id), from)))
+		return
+	}
+	if toRevision == nil {
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorRevision(strconv.Itoa(id), to)))
+		return
+	}
+	diff.Changes = utils.DiffWords(fromRevision.Message, toRevision.Message)
+
+	resp, _ := diff.MarshalJSON()
+	utils.MakeResponse(w, 200, resp)
+}
+
+// GetUserPosts посты пользователя во всех форумах, since - id последнего поста предыдущей страницы
+func(h *PostHandlers) GetUserPosts(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	nickname := params["nickname"]
+	queryParams := r.URL.Query()
+	var limit, since, desc string
+	if limit = queryParams.Get("limit"); limit == "" {
+		limit = "1"
+	}
+	since = queryParams.Get("since")
+	if desc = queryParams.Get("desc"); desc == "" {
+		desc = "false"
+	}
+	forum := queryParams.Get("forum")
+	if n, err := strconv.Atoi(limit); err != nil || n < 0 {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("limit", limit)))
+		return
+	}
+	if _, err := strconv.ParseInt(since, 10, 64); since != "" && err != nil {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("since", since)))
+		return
+	}
+	if desc != "true" && desc != "false" {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("desc", desc)))
+		return
+	}
+
+	result, err := h.posts.GetUserPosts(r.Context(), nickname, limit, since, desc, forum)
+
+	switch err {
+	case nil:
+		resp, _ := swag.WriteJSON(result)
+		utils.MakeResponse(w, 200, resp)
+	case models.UserNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorUser(nickname)))
 	default:
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 	}
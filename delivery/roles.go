@@ -0,0 +1,83 @@
+package delivery
+
+import (
+	"net/http"
+
+	"github.com/AntonPriyma/db_forum/models"
+	"github.com/AntonPriyma/db_forum/repository"
+	"github.com/AntonPriyma/db_forum/utils"
+	"github.com/go-openapi/swag"
+	"github.com/gorilla/mux"
+)
+
+type RolesHandlers struct {
+	roles  repository.RolesRepository
+	forums repository.ForumRepository
+	access *Access
+}
+
+func NewRolesHandlers(roles repository.RolesRepository, forums repository.ForumRepository, access *Access) *RolesHandlers {
+	return &RolesHandlers{roles: roles, forums: forums, access: access}
+}
+
+// GetModerators назначенные модераторы форума
+func (h *RolesHandlers) GetModerators(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	result, err := h.roles.GetModerators(r.Context(), slug)
+	switch err {
+	case nil:
+		resp, _ := swag.WriteJSON(result)
+		utils.MakeResponse(w, 200, resp)
+	case models.ForumNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorForum(slug)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// GrantModerator назначение модератора, может владелец форума или администратор
+func (h *RolesHandlers) GrantModerator(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	slug, nickname := params["slug"], params["nickname"]
+	if !h.access.permitOwner(w, r, h.forums, slug) {
+		return
+	}
+
+	err := h.roles.GrantModerator(r.Context(), slug, nickname)
+	h.writeModerators(w, r, slug, nickname, err)
+}
+
+// RevokeModerator снятие модератора, может владелец форума или администратор
+func (h *RolesHandlers) RevokeModerator(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	slug, nickname := params["slug"], params["nickname"]
+	if !h.access.permitOwner(w, r, h.forums, slug) {
+		return
+	}
+
+	err := h.roles.RevokeModerator(r.Context(), slug, nickname)
+	h.writeModerators(w, r, slug, nickname, err)
+}
+
+// writeModerators ответ на назначение и снятие: текущий список модераторов или ошибка
+func (h *RolesHandlers) writeModerators(w http.ResponseWriter, r *http.Request, slug, nickname string, err error) {
+	var result *models.Users
+	if err == nil {
+		result, err = h.roles.GetModerators(r.Context(), slug)
+	}
+
+	switch err {
+	case nil:
+		resp, _ := swag.WriteJSON(result)
+		utils.MakeResponse(w, 200, resp)
+	case models.ForumNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorForum(slug)))
+	case models.UserNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorUser(nickname)))
+	case models.ModeratorNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorModerator(slug, nickname)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
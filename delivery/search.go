@@ -0,0 +1,93 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AntonPriyma/db_forum/models"
+	"github.com/AntonPriyma/db_forum/repository"
+	"github.com/AntonPriyma/db_forum/utils"
+	"github.com/go-openapi/swag"
+)
+
+type SearchHandlers struct {
+	search  repository.SearchRepository
+	threads repository.ThreadDBRepository
+}
+
+func NewSearchHandlers(search repository.SearchRepository, threads repository.ThreadDBRepository) *SearchHandlers {
+	return &SearchHandlers{search: search, threads: threads}
+}
+
+// Search полнотекстовый поиск: q обязателен, type post или thread,
+// фильтры forum, thread (slug или id), author, since и until в RFC3339, пагинация limit и offset
+func (h *SearchHandlers) Search(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	query := &models.SearchQuery{
+		Query:  queryParams.Get("q"),
+		Type:   queryParams.Get("type"),
+		Forum:  queryParams.Get("forum"),
+		Author: queryParams.Get("author"),
+	}
+	if query.Query == "" {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("q", "")))
+		return
+	}
+	if query.Type != "" && query.Type != models.SearchPost && query.Type != models.SearchThread {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("type", query.Type)))
+		return
+	}
+
+	var limit, offset string
+	if limit = queryParams.Get("limit"); limit == "" {
+		limit = "1"
+	}
+	if offset = queryParams.Get("offset"); offset == "" {
+		offset = "0"
+	}
+	for name, value := range map[string]string{"limit": limit, "offset": offset} {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam(name, value)))
+			return
+		}
+		if name == "limit" {
+			query.Limit = n
+		} else {
+			query.Offset = n
+		}
+	}
+
+	for name, target := range map[string]**time.Time{"since": &query.Since, "until": &query.Until} {
+		value := queryParams.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam(name, value)))
+			return
+		}
+		*target = &t
+	}
+
+	if param := queryParams.Get("thread"); param != "" {
+		thread, err := h.threads.GetThread(r.Context(), param)
+		if err != nil {
+			utils.MakeResponse(w, 404, []byte(utils.MakeErrorThread(param)))
+			return
+		}
+		query.Thread = thread.ID
+	}
+
+	result, err := h.search.Search(r.Context(), query)
+
+	switch err {
+	case nil:
+		resp, _ := swag.WriteJSON(result)
+		utils.MakeResponse(w, 200, resp)
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
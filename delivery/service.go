@@ -7,19 +7,20 @@ import (
 )
 
 type ServiceHandlers struct {
-	service *repository.DBService
+	service repository.ServiceRepository
+	access  *Access
 }
 
-func NewServiceHandlers(service *repository.DBService) *ServiceHandlers {
-	return &ServiceHandlers{service: service}
+func NewServiceHandlers(service repository.ServiceRepository, access *Access) *ServiceHandlers {
+	return &ServiceHandlers{service: service, access: access}
 }
 
-func(h *ServiceHandlers) NewServiceHandlers(service *repository.DBService) *ServiceHandlers {
+func(h *ServiceHandlers) NewServiceHandlers(service repository.ServiceRepository) *ServiceHandlers {
 	return &ServiceHandlers{service: service}
 }
 
 func(h *ServiceHandlers) GetStatus(w http.ResponseWriter, r *http.Request) {
-	status, err := h.service.GetStatus()
+	status, err := h.service.GetStatus(r.Context())
 	if err != nil {
 		utils.WriteEasyjson(w, http.StatusInternalServerError, err)
 		return
@@ -28,8 +29,12 @@ func(h *ServiceHandlers) GetStatus(w http.ResponseWriter, r *http.Request) {
 	utils.WriteEasyjson(w, http.StatusOK, status)
 }
 
+// Clear очистка всех таблиц, только для администратора
 func(h *ServiceHandlers) Clear(w http.ResponseWriter, r *http.Request) {
-	err := h.service.Load()
+	if !h.access.permit(w, r, "") {
+		return
+	}
+	err := h.service.Load(r.Context())
 	if err != nil {
 		utils.WriteEasyjson(w, http.StatusInternalServerError, err)
 		return
@@ -8,14 +8,33 @@ import (
 	"github.com/gorilla/mux"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 )
 
 type ThreadHandlers struct {
 	threads repository.ThreadDBRepository
+	access  *Access
 }
 
-func NewThreadHandlers(threads repository.ThreadDBRepository) *ThreadHandlers {
-	return &ThreadHandlers{threads: threads}
+func NewThreadHandlers(threads repository.ThreadDBRepository, access *Access) *ThreadHandlers {
+	return &ThreadHandlers{threads: threads, access: access}
+}
+
+// permitThread пропускает модераторов форума треда и администратора, withAuthor - ещё и автора треда
+func(h *ThreadHandlers) permitThread(w http.ResponseWriter, r *http.Request, param string, withAuthor bool) bool {
+	thread, err := h.threads.GetThread(r.Context(), param)
+	switch err {
+	case nil:
+		if withAuthor {
+			return h.access.permit(w, r, thread.Forum, thread.Author)
+		}
+		return h.access.permit(w, r, thread.Forum)
+	case models.ThreadNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorThread(param)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+	return false
 }
 
 func(h *ThreadHandlers) CreateThread(w http.ResponseWriter, r *http.Request) {
@@ -36,8 +55,11 @@ func(h *ThreadHandlers) CreateThread(w http.ResponseWriter, r *http.Request) {
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 		return
 	}
+	if !authorize(w, r, thread.Author) {
+		return
+	}
 
-	result, err := h.threads.Create(thread)
+	result, err := h.threads.Create(r.Context(), thread)
 
 	switch err {
 	case nil:
@@ -48,6 +70,8 @@ func(h *ThreadHandlers) CreateThread(w http.ResponseWriter, r *http.Request) {
 	case models.ThreadIsExist:
 		resp, _ := result.MarshalJSON()
 		utils.MakeResponse(w, 409, resp)
+	case models.ForumArchived:
+		utils.MakeResponse(w, 409, []byte(utils.MakeErrorArchived(slug)))
 	default:
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 	}
@@ -71,8 +95,16 @@ func(h *ThreadHandlers) UpdateThread(w http.ResponseWriter, r *http.Request) {
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 		return
 	}
+	// числовой slug не отличить от id в /thread/{slug_or_id}
+	if _, err := strconv.Atoi(threadUpdate.Slug); err == nil {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("slug", threadUpdate.Slug)))
+		return
+	}
+	if !h.permitThread(w, r, param, true) {
+		return
+	}
 
-	result, err := h.threads.UpdateThreadDB(threadUpdate, param)
+	result, err := h.threads.UpdateThreadDB(r.Context(), threadUpdate, param)
 
 	switch err {
 	case nil:
@@ -80,6 +112,9 @@ func(h *ThreadHandlers) UpdateThread(w http.ResponseWriter, r *http.Request) {
 		utils.MakeResponse(w, 200, resp)
 	case models.PostNotFound:
 		utils.MakeResponse(w, 404, []byte(utils.MakeErrorThread(param)))
+	case models.ThreadIsExist:
+		resp, _ := result.MarshalJSON()
+		utils.MakeResponse(w, 409, resp)
 	default:
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 	}
@@ -100,8 +135,21 @@ func(h *ThreadHandlers) GetThreadsByForum(w http.ResponseWriter, r *http.Request
 	if desc = queryParams.Get("desc"); desc == ""{
 		desc = "false"
 	}
+	// скрытые модераторами треды по умолчанию не показываем, а с deleted=true - только модераторам
+	showDeleted := "false"
+	if value := queryParams.Get("deleted"); value != "" {
+		withDeleted, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("deleted", value)))
+			return
+		}
+		if withDeleted && !h.access.permit(w, r, slug) {
+			return
+		}
+		showDeleted = strconv.FormatBool(withDeleted)
+	}
 
-	result, err := h.threads.GetThreadsByForum(slug, limit, since, desc)
+	result, err := h.threads.GetThreadsByForum(r.Context(), slug, limit, since, desc, showDeleted)
 	switch err {
 	case nil:
 		resp, _ := swag.WriteJSON(result)
@@ -124,17 +172,66 @@ func(h *ThreadHandlers) Vote(w http.ResponseWriter, r *http.Request) {
 	}
 	vote := &models.Vote{}
 	err = vote.UnmarshalJSON(body)
+	if err != nil {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("body", err.Error())))
+		return
+	}
+	if vote.Nickname == "" {
+		vote.Nickname, _ = utils.Caller(r.Context())
+	}
+	if !vote.IsValid() {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("voice", strconv.Itoa(vote.Voice))))
+		return
+	}
+	if !authorize(w, r, vote.Nickname) {
+		return
+	}
 
-	result, err := h.threads.MakeThreadVoteDB(vote, param)
+	result, err := h.threads.MakeThreadVoteDB(r.Context(), vote, param)
 
 	switch err {
 	case nil:
 		resp, _ := result.MarshalJSON()
 		utils.MakeResponse(w, 200, resp)
-	case models.ForumNotFound:
+	case models.ThreadNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorThread(param)))
+	case models.UserNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorUser(vote.Nickname)))
+	case models.InvalidVoice:
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("voice", strconv.Itoa(vote.Voice))))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// RetractVote отзыв голоса. Голосующий берётся из ?nickname=, а без него - из токена
+func(h *ThreadHandlers) RetractVote(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	param := params["slug_or_id"]
+	nickname := r.URL.Query().Get("nickname")
+	if nickname == "" {
+		nickname, _ = utils.Caller(r.Context())
+	}
+	if nickname == "" {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("nickname", nickname)))
+		return
+	}
+	if !authorize(w, r, nickname) {
+		return
+	}
+
+	result, err := h.threads.RetractThreadVote(r.Context(), nickname, param)
+
+	switch err {
+	case nil:
+		resp, _ := result.MarshalJSON()
+		utils.MakeResponse(w, 200, resp)
+	case models.ThreadNotFound:
 		utils.MakeResponse(w, 404, []byte(utils.MakeErrorThread(param)))
 	case models.UserNotFound:
-		utils.MakeResponse(w, 404, []byte(utils.MakeErrorUser(param)))
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorUser(nickname)))
+	case models.VoteNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorVote(param, nickname)))
 	default:
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 	}
@@ -144,7 +241,88 @@ func(h *ThreadHandlers) GetThread(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	param := params["slug_or_id"]
 
-	result, err := h.threads.GetThread(param)
+	result, err := h.threads.GetThread(r.Context(), param)
+
+	switch err {
+	case nil:
+		resp, _ := result.MarshalJSON()
+		utils.MakeResponse(w, 200, resp)
+	case models.ThreadNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorThread(param)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// DeleteThread удаление треда вместе со всеми постами и голосами
+func(h *ThreadHandlers) DeleteThread(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	param := params["slug_or_id"]
+	if !h.permitThread(w, r, param, false) {
+		return
+	}
+
+	result, err := h.threads.Delete(r.Context(), param)
+
+	switch err {
+	case nil:
+		resp, _ := result.MarshalJSON()
+		utils.MakeResponse(w, 200, resp)
+	case models.ThreadNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorThread(param)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// HideThread скрытие треда модератором, посты и голоса остаются на месте
+func(h *ThreadHandlers) HideThread(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	param := params["slug_or_id"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+	hide := &models.Hide{}
+	err = hide.UnmarshalJSON(body)
+	if err != nil {
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+		return
+	}
+	if hide.Nickname == "" {
+		hide.Nickname, _ = utils.Caller(r.Context())
+	}
+	if !authorize(w, r, hide.Nickname) || !h.permitThread(w, r, param, false) {
+		return
+	}
+
+	result, err := h.threads.Hide(r.Context(), param, hide.Nickname)
+
+	switch err {
+	case nil:
+		resp, _ := result.MarshalJSON()
+		utils.MakeResponse(w, 200, resp)
+	case models.ThreadNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorThread(param)))
+	case models.UserNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorUser(hide.Nickname)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// RestoreThread возвращает скрытый тред
+func(h *ThreadHandlers) RestoreThread(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	param := params["slug_or_id"]
+	if !h.permitThread(w, r, param, false) {
+		return
+	}
+
+	result, err := h.threads.Restore(r.Context(), param)
 
 	switch err {
 	case nil:
@@ -156,3 +334,43 @@ func(h *ThreadHandlers) GetThread(w http.ResponseWriter, r *http.Request) {
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 	}
 }
+
+// GetUserThreads треды пользователя во всех форумах, since - id последнего треда прошлой страницы
+func(h *ThreadHandlers) GetUserThreads(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	nickname := params["nickname"]
+	queryParams := r.URL.Query()
+	var limit, since, desc string
+	if limit = queryParams.Get("limit"); limit == "" {
+		limit = "1"
+	}
+	since = queryParams.Get("since")
+	if desc = queryParams.Get("desc"); desc == "" {
+		desc = "false"
+	}
+	forum := queryParams.Get("forum")
+	if n, err := strconv.Atoi(limit); err != nil || n < 0 {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("limit", limit)))
+		return
+	}
+	if _, err := strconv.ParseInt(since, 10, 32); since != "" && err != nil {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("since", since)))
+		return
+	}
+	if desc != "true" && desc != "false" {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("desc", desc)))
+		return
+	}
+
+	result, err := h.threads.GetUserThreads(r.Context(), nickname, limit, since, desc, forum)
+
+	switch err {
+	case nil:
+		resp, _ := swag.WriteJSON(result)
+		utils.MakeResponse(w, 200, resp)
+	case models.UserNotFound:
+		utils.MakeResponse(w, 404, []byte(utils.MakeErrorUser(nickname)))
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/go-openapi/swag"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 
 
@@ -26,7 +27,7 @@ func(h *UsersHandlers) GetUser(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	nickname := params["nickname"]
 
-	result, err := h.users.GetUserByNickname(nickname)
+	result, err := h.users.GetUserByNickname(r.Context(), nickname)
 
 	switch err {
 	case nil:
@@ -53,12 +54,21 @@ func(h *UsersHandlers)CreateUser(w http.ResponseWriter, r *http.Request) {
 	user := &models.User{}
 	err = user.UnmarshalJSON(body)
 	user.Nickname = nickname
+	// карма считается по голосам и из запроса не принимается
+	user.Karma = 0
 
 	if err != nil {
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 		return
 	}
-	result, err := h.users.Create(user)
+	if user.Password != "" {
+		if user.Password, err = hashPassword(user.Password); err != nil {
+			utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("password", err.Error())))
+			return
+		}
+	}
+	result, err := h.users.Create(r.Context(), user)
+	user.Password = ""
 
 	switch err {
 	case nil:
@@ -86,12 +96,17 @@ func(h *UsersHandlers)UpdateUser(w http.ResponseWriter, r *http.Request) {
 	user := &models.User{}
 	err = user.UnmarshalJSON(body)
 	user.Nickname = nickname
+	// смена пароля через профиль не поддерживается
+	user.Password = ""
 
 	if err != nil {
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 		return
 	}
-	err = h.users.Save(user)
+	if !authorize(w, r, nickname) {
+		return
+	}
+	err = h.users.Save(r.Context(), user)
 
 	switch err {
 	case nil:
@@ -105,3 +120,63 @@ func(h *UsersHandlers)UpdateUser(w http.ResponseWriter, r *http.Request) {
 		utils.MakeResponse(w, 500, []byte(err.Error()))
 	}
 }
+
+// GetUsers список всех пользователей по nickname с пагинацией limit, since, desc
+func(h *UsersHandlers) GetUsers(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	var limit, since, desc string
+	if limit = queryParams.Get("limit"); limit == "" {
+		limit = "1"
+	}
+	since = queryParams.Get("since")
+	if desc = queryParams.Get("desc"); desc == "" {
+		desc = "false"
+	}
+	if n, err := strconv.Atoi(limit); err != nil || n < 0 {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("limit", limit)))
+		return
+	}
+	if desc != "true" && desc != "false" {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("desc", desc)))
+		return
+	}
+
+	result, err := h.users.GetUsers(r.Context(), limit, since, desc)
+
+	switch err {
+	case nil:
+		resp, _ := swag.WriteJSON(result)
+		utils.MakeResponse(w, 200, resp)
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
+// SearchUsers поиск пользователей по началу nickname, fullname или email для автодополнения
+func(h *UsersHandlers) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	prefix := queryParams.Get("prefix")
+	var limit string
+	if limit = queryParams.Get("limit"); limit == "" {
+		limit = "1"
+	}
+	if prefix == "" {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("prefix", prefix)))
+		return
+	}
+	if n, err := strconv.Atoi(limit); err != nil || n < 0 {
+		utils.MakeResponse(w, 400, []byte(utils.MakeErrorBadParam("limit", limit)))
+		return
+	}
+
+	result, err := h.users.SearchUsers(r.Context(), prefix, limit)
+
+	switch err {
+	case nil:
+		resp, _ := swag.WriteJSON(result)
+		utils.MakeResponse(w, 200, resp)
+	default:
+		utils.MakeResponse(w, 500, []byte(err.Error()))
+	}
+}
+
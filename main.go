@@ -1,13 +1,20 @@
 package main
 
 import (
-	"log"
+	"context"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/AntonPriyma/db_forum/config"
 	"github.com/AntonPriyma/db_forum/delivery"
+	"github.com/AntonPriyma/db_forum/metrics"
 	"github.com/AntonPriyma/db_forum/repository"
+	"github.com/AntonPriyma/db_forum/utils"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
+	log "github.com/sirupsen/logrus"
 )
 
 // Handler структура хэндлера запросов
@@ -15,61 +22,195 @@ type Handler struct {
 	Router *mux.Router
 }
 
-func main() {
-
+// repositories реализации репозиториев выбранного хранилища
+type repositories struct {
+	users   repository.UsersRepository
+	forums  repository.ForumRepository
+	threads repository.ThreadDBRepository
+	posts   repository.PostRepository
+	search  repository.SearchRepository
+	roles   repository.RolesRepository
+	service repository.ServiceRepository
+}
 
-	dbService := repository.NewDBService()
+func newPostgresRepositories(dbService *repository.DBService) *repositories {
+	forumRepo := repository.NewForumRepositoryImpl(repository.GetDB())
+	usersRepo := repository.NewUsersRepositoryImpl(repository.GetDB(), forumRepo)
+	threadsRepo := repository.NewThreadDBRepositoryImpl(repository.GetDB(), forumRepo)
+	postsRepo := repository.NewPostDBRepositoryImpl(usersRepo, threadsRepo, forumRepo, repository.GetDB())
+
+	return &repositories{
+		users:   usersRepo,
+		forums:  forumRepo,
+		threads: threadsRepo,
+		posts:   postsRepo,
+		search:  repository.NewSearchRepositoryImpl(repository.GetDB()),
+		roles:   repository.NewRolesRepositoryImpl(repository.GetDB()),
+		service: dbService,
+	}
+}
 
-	connectError := repository.ConnetctDB(dbService, "docker", "docker", "localhost", "docker")
-	if connectError != nil {
-		log.Fatalf("cant open database connection: %s", connectError.Message)
+func newMemoryRepositories() *repositories {
+	store := repository.NewMemoryStore()
+
+	return &repositories{
+		users:   repository.NewUsersMemoryRepository(store),
+		forums:  repository.NewForumMemoryRepository(store),
+		threads: repository.NewThreadMemoryRepository(store),
+		posts:   repository.NewPostMemoryRepository(store),
+		search:  repository.NewSearchMemoryRepository(store),
+		roles:   repository.NewRolesMemoryRepository(store),
+		service: repository.NewMemoryService(store),
 	}
-	forumRepo := repository.NewForumRepositoryImpl(repository.GetDB())
-	usersRepo := repository.NewUsersRepositoryImpl(repository.GetDB(),forumRepo)
-	threadsRepo := repository.NewThreadDBRepositoryImpl(repository.GetDB(),forumRepo)
-	postsRepo := repository.NewPostDBRepositoryImpl(usersRepo,threadsRepo,forumRepo,repository.GetDB())
+}
 
+// newRouter роутер со всеми ручками и middleware поверх выбранных репозиториев
+func newRouter(cfg *config.Config, repos *repositories) *mux.Router {
+	access := delivery.NewAccess(repos.roles)
+	users := delivery.NewUsersHandlers(repos.users)
+	threads := delivery.NewThreadHandlers(repos.threads, access)
+	posts := delivery.NewPostHandlers(repos.posts, repos.users, cfg.DeleteChildren, access)
+	forums := delivery.NewForumHandlers(repos.forums, repos.users, access)
+	roles := delivery.NewRolesHandlers(repos.roles, repos.forums, access)
+	search := delivery.NewSearchHandlers(repos.search, repos.threads)
+	service := delivery.NewServiceHandlers(repos.service, access)
+	health := delivery.NewHealthHandlers(repos.service, cfg.ReadyTimeout)
+	tokens := utils.NewTokens([]byte(cfg.Auth.Secret))
+	auth := delivery.NewAuthHandlers(repos.users, tokens, cfg.Auth.TokenTTL)
 
-	users := delivery.NewUsersHandlers(usersRepo)
-	threads := delivery.NewThreadHandlers(threadsRepo)
-	posts := delivery.NewPostHandlers(postsRepo, usersRepo)
-	forums := delivery.NewForumHandlers(forumRepo, usersRepo)
-	service := delivery.NewServiceHandlers(dbService)
 	r := mux.NewRouter()
+	r.Use(
+		delivery.RequestLogger(log.StandardLogger()),
+		metrics.Middleware,
+		delivery.Timeout(cfg.RequestTimeout, cfg.RouteTimeouts),
+		delivery.Authenticate(tokens, cfg.Auth.Required),
+	)
+	r.HandleFunc("/auth/login", auth.Login).Methods("POST")
+
 	r.HandleFunc("/user/{nickname}/profile", users.GetUser).Methods("GET")
 	r.HandleFunc("/user/{nickname}/create", users.CreateUser).Methods("POST")
 	r.HandleFunc("/user/{nickname}/profile", users.UpdateUser).Methods("POST")
+	r.HandleFunc("/users", users.GetUsers).Methods("GET")
+	r.HandleFunc("/users/search", users.SearchUsers).Methods("GET")
+	r.HandleFunc("/user/{nickname}/posts", posts.GetUserPosts).Methods("GET")
+	r.HandleFunc("/user/{nickname}/threads", threads.GetUserThreads).Methods("GET")
 
 	r.HandleFunc("/forum/create", forums.CreateForum).Methods("POST")
+	r.HandleFunc("/forums", forums.GetForums).Methods("GET")
 	r.HandleFunc("/forum/{slug}/details", forums.GetForum).Methods("GET")
+	r.HandleFunc("/forum/{slug}/details", forums.UpdateForum).Methods("POST")
+	r.HandleFunc("/forum/{slug}", forums.DeleteForum).Methods("DELETE")
 	r.HandleFunc("/forum/{slug}/create", threads.CreateThread).Methods("POST")
 	r.HandleFunc("/forum/{slug}/threads", threads.GetThreadsByForum).Methods("GET")
 	r.HandleFunc("/forum/{slug}/users", forums.GetForumUsers).Methods("GET")
+	r.HandleFunc("/forum/{slug}/children", forums.GetForumChildren).Methods("GET")
+	r.HandleFunc("/forum/{slug}/moderators", roles.GetModerators).Methods("GET")
+	r.HandleFunc("/forum/{slug}/moderators/{nickname}", roles.GrantModerator).Methods("PUT")
+	r.HandleFunc("/forum/{slug}/moderators/{nickname}", roles.RevokeModerator).Methods("DELETE")
 
 	r.HandleFunc("/thread/{slug_or_id}/create", posts.CreatePosts).Methods("POST")
 	r.HandleFunc("/thread/{slug_or_id}/vote", threads.Vote).Methods("POST")
+	r.HandleFunc("/thread/{slug_or_id}/vote", threads.RetractVote).Methods("DELETE")
 	r.HandleFunc("/thread/{slug_or_id}/details", threads.GetThread).Methods("GET")
 
-
 	r.HandleFunc("/thread/{slug_or_id}/posts", posts.GetPosts).Methods("GET")
-	r.HandleFunc("/thread/{slug_or_id}/details", threads.UpdateThread).Methods("POST")
-
-
+	r.HandleFunc("/thread/{slug_or_id}/details", threads.UpdateThread).Methods("POST", "PATCH")
+	r.HandleFunc("/thread/{slug_or_id}", threads.DeleteThread).Methods("DELETE")
+	r.HandleFunc("/thread/{slug_or_id}/hide", threads.HideThread).Methods("POST")
 
 	r.HandleFunc("/post/{id:[0-9]+}/details", posts.GetPost).Methods("GET")
 	r.HandleFunc("/post/{id:[0-9]+}/details", posts.UpdatePost).Methods("POST")
+	r.HandleFunc("/post/{id:[0-9]+}", posts.DeletePost).Methods("DELETE")
+	r.HandleFunc("/post/{id:[0-9]+}/hide", posts.HidePost).Methods("POST")
+	r.HandleFunc("/post/{id:[0-9]+}/vote", posts.VotePost).Methods("POST")
+	r.HandleFunc("/post/{id:[0-9]+}/history", posts.GetPostHistory).Methods("GET")
+	r.HandleFunc("/post/{id:[0-9]+}/diff", posts.GetPostDiff).Methods("GET")
+
+	r.HandleFunc("/admin/thread/{slug_or_id}/restore", threads.RestoreThread).Methods("POST")
+	r.HandleFunc("/admin/post/{id:[0-9]+}/restore", posts.RestorePost).Methods("POST")
+
+	r.HandleFunc("/search", search.Search).Methods("GET")
 
 	r.HandleFunc("/service/status", service.GetStatus).Methods("GET")
 	r.HandleFunc("/service/clear", service.Clear).Methods("POST")
 
+	r.HandleFunc("/healthz", health.Live).Methods("GET")
+	r.HandleFunc("/readyz", health.Ready).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
 
-	h := cors.AllowAll().Handler(r)
-
+	return r
+}
 
-	port := "5000"
-	log.Printf("MainService successfully started at port %s", port)
-	err := http.ListenAndServe(":"+port, h)
+func main() {
+	cfg, args, err := config.Load(os.Args[1:])
 	if err != nil {
+		log.Fatalf("cant load config: %s", err)
+	}
+	log.SetLevel(cfg.Level())
+	log.SetFormatter(cfg.Formatter())
+
+	if len(args) > 0 {
+		if args[0] != "migrate" && args[0] != "admin" {
+			log.Fatalf("unknown command %q, %s; %s", args[0], migrateUsage, adminUsage)
+		}
+		if cfg.Storage != config.StoragePostgres {
+			log.Fatalf("%s needs postgres storage, got %q", args[0], cfg.Storage)
+		}
+		if connectError := repository.ConnetctDB(repository.NewDBService(), cfg.Database); connectError != nil {
+			log.Fatalf("cant open database connection: %s", connectError.Message)
+		}
+		if args[0] == "admin" {
+			err = runAdmin(repository.NewRolesRepositoryImpl(repository.GetDB()), args[1:])
+		} else {
+			err = runMigrate(repository.NewMigrator(repository.GetDB()), args[1:])
+		}
+		if err != nil {
+			log.Fatalf("%s: %s", args[0], err)
+		}
+		return
+	}
+
+	var repos *repositories
+	switch cfg.Storage {
+	case config.StorageMemory:
+		log.Warn("using in-memory storage, data is lost on restart")
+		repos = newMemoryRepositories()
+	default:
+		dbService := repository.NewDBService()
+		if connectError := repository.ConnetctDB(dbService, cfg.Database); connectError != nil {
+			log.Fatalf("cant open database connection: %s", connectError.Message)
+		}
+		if err = metrics.RegisterPool(repository.GetDB()); err != nil {
+			log.Fatalf("cant register pool metrics: %s", err)
+		}
+		repos = newPostgresRepositories(dbService)
+	}
+
+	h := cors.AllowAll().Handler(newRouter(cfg, repos))
+
+	server := &http.Server{Addr: cfg.Listen, Handler: h}
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Infof("MainService successfully started at %s", cfg.Listen)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err = <-serverErr:
 		log.Fatalf("cant start main server. err: %s", err.Error())
+	case sig := <-stop:
+		log.Infof("got %s, shutting down, waiting up to %s for in-flight requests", sig, cfg.ShutdownTimeout)
+	}
+
+	// перестаём принимать соединения и ждём активные запросы, потом закрываем пул
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err = server.Shutdown(ctx); err != nil {
+		log.Errorf("graceful shutdown failed: %s", err)
 	}
+	repository.CloseDB()
+	log.Info("MainService stopped")
 }
@@ -0,0 +1,1639 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AntonPriyma/db_forum/config"
+	"github.com/AntonPriyma/db_forum/models"
+	"github.com/AntonPriyma/db_forum/repository"
+	"github.com/AntonPriyma/db_forum/utils"
+)
+
+// testServer поднимает роутер из main поверх memory хранилища, закрывать вызывающему.
+// Если задана DB_FORUM_TEST_POSTGRES, те же проверки идут против postgres из переменных DB_FORUM_*,
+// база при этом очищается через ServiceRepository.Load. configure правит конфиг до сборки роутера.
+func testServer(t *testing.T, configure ...func(cfg *config.Config)) *httptest.Server {
+	t.Helper()
+
+	server, _ := testServerRepos(t, configure...)
+	return server
+}
+
+// testServerRepos как testServer, но отдаёт и репозитории, чтобы подготовить то, чего нет в API
+func testServerRepos(t *testing.T, configure ...func(cfg *config.Config)) (*httptest.Server, *repositories) {
+	t.Helper()
+
+	cfg, _, err := config.Load([]string{"-auth-secret", testAuthSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// большинство тестов не про авторизацию и пишут без токена, TestAuthRequired включает обратно
+	cfg.Auth.Required = false
+	for _, f := range configure {
+		f(cfg)
+	}
+
+	repos := newMemoryRepositories()
+	if os.Getenv("DB_FORUM_TEST_POSTGRES") != "" {
+		dbService := repository.NewDBService()
+		if repository.GetDB() == nil {
+			if err := repository.ConnetctDB(dbService, cfg.Database); err != nil {
+				t.Fatal(err.Message)
+			}
+		}
+		if _, err := repository.NewMigrator(repository.GetDB()).Up(); err != nil {
+			t.Fatal(err)
+		}
+		repos = newPostgresRepositories(dbService)
+	}
+
+	if err := repos.service.Load(context.Background()); err != nil {
+		t.Fatal(err.Message)
+	}
+	return httptest.NewServer(newRouter(cfg, repos)), repos
+}
+
+// testAuthSecret ключ подписи токенов тестового сервера, см. tokenFor
+const testAuthSecret = "test-secret"
+
+// tokenFor токен сессии nickname без входа по паролю, подписанный ключом тестового сервера
+func tokenFor(nickname string) string {
+	return utils.NewTokens([]byte(testAuthSecret)).Sign(nickname, time.Now().Add(time.Hour))
+}
+
+// expect делает запрос и проверяет код ответа, тело разбирается в out, если он не nil
+func expect(t *testing.T, server *httptest.Server, method, path, body string, code int, out interface{}) []byte {
+	t.Helper()
+
+	return expectAs(t, server, "", method, path, body, code, out)
+}
+
+// expectAs как expect, но с токеном сессии, пустой token - анонимный запрос
+func expectAs(t *testing.T, server *httptest.Server, token, method, path, body string, code int, out interface{}) []byte {
+	t.Helper()
+
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != code {
+		t.Fatalf("%s %s: status %d, want %d, body %s", method, path, resp.StatusCode, code, data)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("%s %s: bad json %q: %s", method, path, data, err)
+		}
+	}
+	return data
+}
+
+// expectMessage проверяет ответ с ошибкой: код и непустое поле message
+func expectMessage(t *testing.T, server *httptest.Server, method, path, body string, code int) {
+	t.Helper()
+
+	expectMessageAs(t, server, "", method, path, body, code)
+}
+
+// expectMessageAs как expectMessage, но с токеном сессии
+func expectMessageAs(t *testing.T, server *httptest.Server, token, method, path, body string, code int) {
+	t.Helper()
+
+	var e models.Error
+	expectAs(t, server, token, method, path, body, code, &e)
+	if e.Message == "" {
+		t.Fatalf("%s %s: empty error message", method, path)
+	}
+}
+
+func jsonBody(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func createUser(t *testing.T, server *httptest.Server, nickname string) *models.User {
+	t.Helper()
+
+	user := &models.User{Fullname: "User " + nickname, Email: nickname + "@example.com", About: "about " + nickname}
+	created := &models.User{}
+	expect(t, server, "POST", "/user/"+nickname+"/create", jsonBody(t, user), http.StatusCreated, created)
+	return created
+}
+
+func createForum(t *testing.T, server *httptest.Server, slug, owner string) *models.Forum {
+	t.Helper()
+
+	forum := &models.Forum{}
+	expect(t, server, "POST", "/forum/create", jsonBody(t, &models.Forum{Slug: slug, Title: "Forum " + slug, Owner: owner}), http.StatusCreated, forum)
+	return forum
+}
+
+func createThread(t *testing.T, server *httptest.Server, forum, author, slug string) *models.Thread {
+	t.Helper()
+
+	thread := &models.Thread{}
+	body := jsonBody(t, &models.Thread{Author: author, Title: "Thread " + slug, Message: "message " + slug, Slug: slug})
+	expect(t, server, "POST", "/forum/"+forum+"/create", body, http.StatusCreated, thread)
+	return thread
+}
+
+func createPosts(t *testing.T, server *httptest.Server, thread string, posts models.Posts) models.Posts {
+	t.Helper()
+
+	created := models.Posts{}
+	expect(t, server, "POST", "/thread/"+thread+"/create", jsonBody(t, posts), http.StatusCreated, &created)
+	if len(created) != len(posts) {
+		t.Fatalf("created %d posts, want %d", len(created), len(posts))
+	}
+	return created
+}
+
+func postIDs(posts models.Posts) []int64 {
+	ids := make([]int64, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+	return ids
+}
+
+func TestUserRoutes(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	user := createUser(t, server, "alice")
+	if user.Nickname != "alice" || user.Email != "alice@example.com" {
+		t.Errorf("created user = %+v", user)
+	}
+
+	conflicts := models.Users{}
+	body := jsonBody(t, &models.User{Fullname: "Other", Email: "ALICE@example.com"})
+	expect(t, server, "POST", "/user/bob/create", body, http.StatusConflict, &conflicts)
+	if len(conflicts) != 1 || conflicts[0].Nickname != "alice" {
+		t.Errorf("conflict by email = %+v, want alice", conflicts)
+	}
+	expect(t, server, "POST", "/user/ALICE/create", jsonBody(t, &models.User{Fullname: "A", Email: "new@example.com"}), http.StatusConflict, &conflicts)
+
+	profile := &models.User{}
+	expect(t, server, "GET", "/user/ALICE/profile", "", http.StatusOK, profile)
+	if *profile != *user {
+		t.Errorf("profile = %+v, want %+v", profile, user)
+	}
+	expectMessage(t, server, "GET", "/user/nobody/profile", "", http.StatusNotFound)
+
+	updated := &models.User{}
+	expect(t, server, "POST", "/user/alice/profile", `{"fullname": "Alice Liddell"}`, http.StatusOK, updated)
+	expect(t, server, "GET", "/user/alice/profile", "", http.StatusOK, profile)
+	if profile.Fullname != "Alice Liddell" || profile.Email != user.Email || profile.About != user.About {
+		t.Errorf("after partial update profile = %+v", profile)
+	}
+	expectMessage(t, server, "POST", "/user/nobody/profile", `{"fullname": "x"}`, http.StatusNotFound)
+
+	createUser(t, server, "bob")
+	expectMessage(t, server, "POST", "/user/bob/profile", `{"email": "alice@example.com"}`, http.StatusConflict)
+}
+
+func TestUserDirectory(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	for _, user := range []*models.User{
+		{Nickname: "carol", Fullname: "Carol Smith", Email: "c@mail.org"},
+		{Nickname: "Alice", Fullname: "Alice Jones", Email: "boss@corp.com"},
+		{Nickname: "bob", Fullname: "Robert Smith", Email: "bob@mail.org"},
+		{Nickname: "al_x", Fullname: "Alex", Email: "x@corp.com"},
+	} {
+		expect(t, server, "POST", "/user/"+user.Nickname+"/create", jsonBody(t, user), http.StatusCreated, nil)
+	}
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"/users?limit=10", "al_x,Alice,bob,carol"},
+		{"/users?limit=2", "al_x,Alice"},
+		{"/users?limit=10&since=alice", "bob,carol"},
+		{"/users?limit=2&since=CAROL&desc=true", "bob,Alice"},
+		{"/users/search?prefix=al&limit=10", "al_x,Alice"},
+		{"/users/search?prefix=AL_&limit=10", "al_x"},
+		{"/users/search?prefix=smith&limit=10", ""},
+		{"/users/search?prefix=rob&limit=10", "bob"},
+		{"/users/search?prefix=BO&limit=10", "Alice,bob"},
+		{"/users/search?prefix=x@&limit=10", "al_x"},
+		{"/users/search?prefix=%25&limit=10", ""},
+		{"/users/search?prefix=c&limit=1", "carol"},
+	} {
+		users := models.Users{}
+		expect(t, server, "GET", tc.path, "", http.StatusOK, &users)
+		if got := nicknames(users); got != tc.want {
+			t.Errorf("GET %s = %s, want %s", tc.path, got, tc.want)
+		}
+	}
+
+	expectMessage(t, server, "GET", "/users?desc=yes", "", http.StatusBadRequest)
+	expectMessage(t, server, "GET", "/users/search", "", http.StatusBadRequest)
+	expectMessage(t, server, "GET", "/users/search?prefix=a&limit=x", "", http.StatusBadRequest)
+}
+
+func TestUserActivity(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "alice")
+	createUser(t, server, "bob")
+	createForum(t, server, "f1", "alice")
+	createForum(t, server, "f2", "alice")
+
+	ids := map[string]int32{}
+	for i, tc := range []struct{ forum, author, slug string }{
+		{"f1", "alice", "a0"},
+		{"f2", "alice", "a1"},
+		{"f1", "bob", "b0"},
+		{"f2", "alice", "a2"},
+	} {
+		thread := &models.Thread{Author: tc.author, Title: "t", Message: "m", Slug: tc.slug, Created: forumTime(i)}
+		expect(t, server, "POST", "/forum/"+tc.forum+"/create", jsonBody(t, thread), http.StatusCreated, thread)
+		ids[tc.slug] = thread.ID
+	}
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"/user/alice/threads?limit=10", "a0,a1,a2"},
+		{"/user/ALICE/threads?limit=2&desc=true", "a2,a1"},
+		{fmt.Sprintf("/user/alice/threads?limit=10&since=%d", ids["a1"]), "a2"},
+		{fmt.Sprintf("/user/alice/threads?limit=10&desc=true&since=%d", ids["a1"]), "a0"},
+		{"/user/alice/threads?limit=10&forum=F2", "a1,a2"},
+		{"/user/bob/threads?limit=10&forum=f2", ""},
+	} {
+		threads := models.Threads{}
+		expect(t, server, "GET", tc.path, "", http.StatusOK, &threads)
+		if got := strings.Join(threadSlugs(threads), ","); got != tc.want {
+			t.Errorf("GET %s = %s, want %s", tc.path, got, tc.want)
+		}
+	}
+
+	// треды с одинаковым created не повторяются и не зацикливают листание
+	for _, slug := range []string{"a3", "a4", "a5"} {
+		thread := &models.Thread{Author: "alice", Title: "t", Message: "m", Slug: slug, Created: forumTime(5)}
+		expect(t, server, "POST", "/forum/f1/create", jsonBody(t, thread), http.StatusCreated, nil)
+	}
+	for desc, want := range map[string]string{"false": "a0,a1,a2,a3,a4,a5", "true": "a5,a4,a3,a2,a1,a0"} {
+		walked := make([]string, 0)
+		since := ""
+		for page := 0; page < 10; page++ {
+			threads := models.Threads{}
+			expect(t, server, "GET", "/user/alice/threads?limit=2&desc="+desc+"&since="+since, "", http.StatusOK, &threads)
+			if len(threads) == 0 {
+				break
+			}
+			walked = append(walked, threadSlugs(threads)...)
+			since = fmt.Sprint(threads[len(threads)-1].ID)
+		}
+		if got := strings.Join(walked, ","); got != want {
+			t.Errorf("user threads by pages desc=%s = %s, want %s", desc, got, want)
+		}
+	}
+
+	first := createPosts(t, server, "a0", models.Posts{{Author: "alice", Message: "p1"}, {Author: "bob", Message: "p2"}})
+	second := createPosts(t, server, "a1", models.Posts{{Author: "alice", Message: "p3"}, {Author: "alice", Message: "p4"}})
+	p1, p3, p4 := first[0].ID, second[0].ID, second[1].ID
+
+	for _, tc := range []struct {
+		path string
+		want []int64
+	}{
+		{"/user/alice/posts?limit=10", []int64{p1, p3, p4}},
+		{"/user/alice/posts?limit=2&desc=true", []int64{p4, p3}},
+		{fmt.Sprintf("/user/alice/posts?limit=10&since=%d", p1), []int64{p3, p4}},
+		{fmt.Sprintf("/user/alice/posts?limit=10&since=%d&desc=true", p4), []int64{p3, p1}},
+		{"/user/alice/posts?limit=10&forum=f1", []int64{p1}},
+	} {
+		posts := models.Posts{}
+		expect(t, server, "GET", tc.path, "", http.StatusOK, &posts)
+		if got := postIDs(posts); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("GET %s = %v, want %v", tc.path, got, tc.want)
+		}
+	}
+
+	expectAs(t, server, tokenFor("alice"), "POST", fmt.Sprintf("/post/%d/hide", p3), `{"nickname": "alice"}`, http.StatusOK, nil)
+	posts := models.Posts{}
+	expect(t, server, "GET", "/user/alice/posts?limit=10", "", http.StatusOK, &posts)
+	if got := postIDs(posts); fmt.Sprint(got) != fmt.Sprint([]int64{p1, p4}) {
+		t.Errorf("user posts after hide = %v", got)
+	}
+
+	expectAs(t, server, tokenFor("alice"), "POST", "/thread/a0/hide", `{"nickname": "alice"}`, http.StatusOK, nil)
+	expect(t, server, "GET", "/user/alice/posts?limit=10", "", http.StatusOK, &posts)
+	if got := postIDs(posts); fmt.Sprint(got) != fmt.Sprint([]int64{p4}) {
+		t.Errorf("user posts after thread hide = %v", got)
+	}
+
+	expectMessage(t, server, "GET", "/user/nobody/posts", "", http.StatusNotFound)
+	expectMessage(t, server, "GET", "/user/nobody/threads", "", http.StatusNotFound)
+	expectMessage(t, server, "GET", "/user/alice/posts?since=x", "", http.StatusBadRequest)
+	expectMessage(t, server, "GET", "/user/alice/threads?since=yesterday", "", http.StatusBadRequest)
+	expectMessage(t, server, "GET", "/user/alice/threads?desc=1", "", http.StatusBadRequest)
+}
+
+// login входит паролем и возвращает токен сессии
+func login(t *testing.T, server *httptest.Server, nickname, password string) string {
+	t.Helper()
+
+	session := &models.Session{}
+	expect(t, server, "POST", "/auth/login", jsonBody(t, &models.Credentials{Nickname: nickname, Password: password}), http.StatusOK, session)
+	if session.Token == "" {
+		t.Fatalf("login %s: empty token", nickname)
+	}
+	return session.Token
+}
+
+func TestAuth(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	user := &models.User{Fullname: "Alice", Email: "alice@example.com", Password: "secret"}
+	data := expect(t, server, "POST", "/user/alice/create", jsonBody(t, user), http.StatusCreated, nil)
+	if strings.Contains(string(data), "password") || strings.Contains(string(data), "secret") {
+		t.Errorf("create response leaks password: %s", data)
+	}
+	data = expect(t, server, "GET", "/user/alice/profile", "", http.StatusOK, nil)
+	if strings.Contains(string(data), "password") {
+		t.Errorf("profile leaks password: %s", data)
+	}
+	createUser(t, server, "bob")
+
+	expectMessage(t, server, "POST", "/auth/login", `{"nickname": "alice", "password": "wrong"}`, http.StatusUnauthorized)
+	expectMessage(t, server, "POST", "/auth/login", `{"nickname": "nobody", "password": "secret"}`, http.StatusUnauthorized)
+	expectMessage(t, server, "POST", "/auth/login", `{"nickname": "bob", "password": ""}`, http.StatusUnauthorized)
+
+	session := &models.Session{}
+	expect(t, server, "POST", "/auth/login", `{"nickname": "ALICE", "password": "secret"}`, http.StatusOK, session)
+	if session.Nickname != "alice" || !session.Expires.After(time.Now()) {
+		t.Errorf("session = %+v", session)
+	}
+	token := session.Token
+
+	expectAs(t, server, token, "POST", "/forum/create", jsonBody(t, &models.Forum{Slug: "f", Title: "f", Owner: "Alice"}), http.StatusCreated, nil)
+	expectAs(t, server, token, "POST", "/forum/create", jsonBody(t, &models.Forum{Slug: "g", Title: "g", Owner: "bob"}), http.StatusForbidden, nil)
+	// без токена пишут как раньше, auth.required выключен
+	createThread(t, server, "f", "bob", "t")
+
+	expectAs(t, server, token, "POST", "/forum/f/create", jsonBody(t, &models.Thread{Author: "bob", Title: "t", Message: "m"}), http.StatusForbidden, nil)
+	expectAs(t, server, token, "POST", "/thread/t/create", jsonBody(t, models.Posts{{Author: "alice", Message: "a"}, {Author: "bob", Message: "b"}}), http.StatusForbidden, nil)
+	expectAs(t, server, token, "POST", "/thread/t/create", jsonBody(t, models.Posts{{Author: "alice", Message: "a"}}), http.StatusCreated, nil)
+	expectAs(t, server, token, "POST", "/thread/t/vote", `{"nickname": "bob", "voice": 1}`, http.StatusForbidden, nil)
+	expectAs(t, server, token, "POST", "/thread/t/vote", `{"nickname": "alice", "voice": 1}`, http.StatusOK, nil)
+	expectAs(t, server, token, "POST", "/user/bob/profile", `{"about": "hacked"}`, http.StatusForbidden, nil)
+	expectAs(t, server, token, "POST", "/user/alice/profile", `{"about": "me"}`, http.StatusOK, nil)
+	expectAs(t, server, token, "POST", "/thread/t/hide", `{"nickname": "bob"}`, http.StatusForbidden, nil)
+
+	expectAs(t, server, "junk", "GET", "/user/alice/profile", "", http.StatusUnauthorized, nil)
+	expectAs(t, server, token+"x", "POST", "/thread/t/vote", `{"nickname": "alice", "voice": 1}`, http.StatusUnauthorized, nil)
+}
+
+func TestAuthRequired(t *testing.T) {
+	if !config.Default().Auth.Required {
+		t.Error("auth.required is off by default")
+	}
+	if _, _, err := config.Load([]string{"-auth-secret", ""}); err == nil {
+		t.Error("config without auth secret is valid")
+	}
+
+	server := testServer(t, func(cfg *config.Config) {
+		cfg.Auth.Required = true
+	})
+	defer server.Close()
+
+	user := &models.User{Fullname: "Alice", Email: "alice@example.com", Password: "secret"}
+	expect(t, server, "POST", "/user/alice/create", jsonBody(t, user), http.StatusCreated, nil)
+	token := login(t, server, "alice", "secret")
+
+	forum := jsonBody(t, &models.Forum{Slug: "f", Title: "f", Owner: "alice"})
+	expectMessage(t, server, "POST", "/forum/create", forum, http.StatusUnauthorized)
+	expectAs(t, server, token, "POST", "/forum/create", forum, http.StatusCreated, nil)
+	expect(t, server, "GET", "/forum/f/details", "", http.StatusOK, nil)
+}
+
+func TestRoles(t *testing.T) {
+	server, repos := testServerRepos(t)
+	defer server.Close()
+
+	tokens := map[string]string{}
+	for _, nickname := range []string{"root", "owner", "mod", "bob"} {
+		user := &models.User{Fullname: nickname, Email: nickname + "@example.com", Password: "pw"}
+		expect(t, server, "POST", "/user/"+nickname+"/create", jsonBody(t, user), http.StatusCreated, nil)
+		tokens[nickname] = login(t, server, nickname, "pw")
+	}
+	if err := repos.roles.SetAdmin(context.Background(), "root", true); err != nil {
+		t.Fatal(err)
+	}
+
+	expectAs(t, server, tokens["owner"], "POST", "/forum/create", jsonBody(t, &models.Forum{Slug: "f", Title: "f", Owner: "owner"}), http.StatusCreated, nil)
+	expectAs(t, server, tokens["bob"], "POST", "/forum/f/create", jsonBody(t, &models.Thread{Author: "bob", Title: "t", Message: "m", Slug: "t"}), http.StatusCreated, nil)
+	posts := models.Posts{}
+	expectAs(t, server, tokens["bob"], "POST", "/thread/t/create", jsonBody(t, models.Posts{{Author: "bob", Message: "first"}}), http.StatusCreated, &posts)
+	post := fmt.Sprintf("/post/%d", posts[0].ID)
+
+	expectAs(t, server, tokens["bob"], "POST", post+"/details", `{"message": "edited"}`, http.StatusOK, nil)
+	expectAs(t, server, tokens["mod"], "POST", post+"/details", `{"message": "moderated"}`, http.StatusForbidden, nil)
+	expectAs(t, server, tokens["mod"], "POST", "/thread/t/hide", `{"nickname": "mod"}`, http.StatusForbidden, nil)
+
+	expectAs(t, server, tokens["bob"], "PUT", "/forum/f/moderators/mod", "", http.StatusForbidden, nil)
+	moderators := models.Users{}
+	expectAs(t, server, tokens["owner"], "PUT", "/forum/f/moderators/MOD", "", http.StatusOK, &moderators)
+	if got := nicknames(moderators); got != "mod" {
+		t.Errorf("moderators after grant = %s", got)
+	}
+	expectAs(t, server, tokens["root"], "PUT", "/forum/f/moderators/bob", "", http.StatusOK, nil)
+	expect(t, server, "GET", "/forum/f/moderators", "", http.StatusOK, &moderators)
+	if got := nicknames(moderators); got != "bob,mod" {
+		t.Errorf("moderators = %s", got)
+	}
+	expectAs(t, server, tokens["owner"], "PUT", "/forum/f/moderators/nobody", "", http.StatusNotFound, nil)
+	expectAs(t, server, tokens["owner"], "PUT", "/forum/nope/moderators/mod", "", http.StatusNotFound, nil)
+
+	expectAs(t, server, tokens["mod"], "POST", post+"/details", `{"message": "moderated"}`, http.StatusOK, nil)
+	history := models.PostRevisions{}
+	expect(t, server, "GET", post+"/history", "", http.StatusOK, &history)
+	if last := history[len(history)-1]; last.Editor != "mod" {
+		t.Errorf("last revision editor = %s, want mod", last.Editor)
+	}
+	expectAs(t, server, tokens["mod"], "POST", "/thread/t/hide", `{"nickname": "mod"}`, http.StatusOK, nil)
+	expectAs(t, server, tokens["mod"], "POST", "/admin/thread/t/restore", "", http.StatusOK, nil)
+
+	expectAs(t, server, tokens["root"], "DELETE", "/forum/f/moderators/bob", "", http.StatusOK, &moderators)
+	if got := nicknames(moderators); got != "mod" {
+		t.Errorf("moderators after revoke = %s", got)
+	}
+	expectAs(t, server, tokens["owner"], "DELETE", "/forum/f/moderators/bob", "", http.StatusNotFound, nil)
+	expectAs(t, server, tokens["bob"], "POST", post+"/hide", `{"nickname": "bob"}`, http.StatusForbidden, nil)
+	expectAs(t, server, tokens["bob"], "DELETE", "/thread/t", "", http.StatusForbidden, nil)
+	expectAs(t, server, tokens["mod"], "DELETE", post, "", http.StatusOK, nil)
+	expectAs(t, server, tokens["mod"], "DELETE", "/thread/404", "", http.StatusNotFound, nil)
+
+	expectAs(t, server, tokens["mod"], "POST", "/forum/f/details", `{"title": "renamed"}`, http.StatusForbidden, nil)
+	expectAs(t, server, tokens["owner"], "POST", "/forum/f/details", `{"title": "renamed"}`, http.StatusOK, nil)
+	expectAs(t, server, tokens["bob"], "DELETE", "/forum/f", "", http.StatusForbidden, nil)
+	expectAs(t, server, tokens["root"], "DELETE", "/forum/f", "", http.StatusOK, nil)
+
+	expectAs(t, server, tokens["owner"], "POST", "/service/clear", "", http.StatusForbidden, nil)
+	expectAs(t, server, tokens["root"], "POST", "/service/clear", "", http.StatusOK, nil)
+}
+
+func TestForumRoutes(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "owner")
+	forum := createForum(t, server, "golang", "OWNER")
+	if forum.Owner != "owner" || forum.Slug != "golang" {
+		t.Errorf("created forum = %+v, want owner nickname from users", forum)
+	}
+
+	existing := &models.Forum{}
+	expect(t, server, "POST", "/forum/create", jsonBody(t, &models.Forum{Slug: "GOLANG", Title: "dup", Owner: "owner"}), http.StatusConflict, existing)
+	if existing.Slug != "golang" || existing.Title != forum.Title {
+		t.Errorf("conflict forum = %+v, want %+v", existing, forum)
+	}
+	expectMessage(t, server, "POST", "/forum/create", jsonBody(t, &models.Forum{Slug: "other", Title: "t", Owner: "nobody"}), http.StatusNotFound)
+
+	details := &models.Forum{}
+	expect(t, server, "GET", "/forum/GoLang/details", "", http.StatusOK, details)
+	if fmt.Sprint(*details) != fmt.Sprint(*forum) {
+		t.Errorf("details = %+v, want %+v", details, forum)
+	}
+	expectMessage(t, server, "GET", "/forum/missing/details", "", http.StatusNotFound)
+
+	threads := models.Threads{}
+	expect(t, server, "GET", "/forum/golang/threads?limit=10", "", http.StatusOK, &threads)
+	if len(threads) != 0 {
+		t.Errorf("threads of empty forum = %d", len(threads))
+	}
+	expectMessage(t, server, "GET", "/forum/missing/threads?limit=10", "", http.StatusNotFound)
+
+	users := models.Users{}
+	expect(t, server, "GET", "/forum/golang/users?limit=10", "", http.StatusOK, &users)
+	if len(users) != 0 {
+		t.Errorf("users of empty forum = %d", len(users))
+	}
+	expectMessage(t, server, "GET", "/forum/missing/users?limit=10", "", http.StatusNotFound)
+}
+
+func TestForumListings(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	for _, nickname := range []string{"carol", "Alice", "bob", "dave"} {
+		createUser(t, server, nickname)
+	}
+	createForum(t, server, "f", "alice")
+
+	for i, author := range []string{"carol", "alice", "bob"} {
+		thread := &models.Thread{
+			Author:  author,
+			Title:   "t",
+			Message: "m",
+			Slug:    fmt.Sprintf("t%d", i),
+			Created: forumTime(i),
+		}
+		expect(t, server, "POST", "/forum/f/create", jsonBody(t, thread), http.StatusCreated, nil)
+	}
+
+	threads := models.Threads{}
+	expect(t, server, "GET", "/forum/f/threads?limit=2", "", http.StatusOK, &threads)
+	if len(threads) != 2 || threads[0].Slug != "t0" || threads[1].Slug != "t1" {
+		t.Errorf("threads asc = %v", threadSlugs(threads))
+	}
+	expect(t, server, "GET", "/forum/f/threads?limit=10&desc=true&since="+forumTime(1).Format("2006-01-02T15:04:05.000Z"), "", http.StatusOK, &threads)
+	if len(threads) != 2 || threads[0].Slug != "t1" || threads[1].Slug != "t0" {
+		t.Errorf("threads desc since = %v", threadSlugs(threads))
+	}
+
+	createPosts(t, server, "t0", models.Posts{{Author: "dave", Message: "hi"}})
+
+	users := models.Users{}
+	expect(t, server, "GET", "/forum/f/users?limit=10", "", http.StatusOK, &users)
+	if got := nicknames(users); got != "Alice,bob,carol,dave" {
+		t.Errorf("forum users = %s", got)
+	}
+	expect(t, server, "GET", "/forum/f/users?limit=2&since=bob&desc=true", "", http.StatusOK, &users)
+	if got := nicknames(users); got != "Alice" {
+		t.Errorf("forum users desc since bob = %s", got)
+	}
+
+	forum := &models.Forum{}
+	expect(t, server, "GET", "/forum/f/details", "", http.StatusOK, forum)
+	if forum.Threads != 3 || forum.Posts != 1 {
+		t.Errorf("forum counters threads=%d posts=%d, want 3 and 1", forum.Threads, forum.Posts)
+	}
+}
+
+func forumSlugs(forums models.Forums) string {
+	slugs := make([]string, 0, len(forums))
+	for _, forum := range forums {
+		slugs = append(slugs, forum.Slug)
+	}
+	return strings.Join(slugs, ",")
+}
+
+func TestForumIndex(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "owner")
+	forums := models.Forums{}
+	expect(t, server, "GET", "/forums?limit=10", "", http.StatusOK, &forums)
+	if len(forums) != 0 {
+		t.Errorf("forums of empty service = %s", forumSlugs(forums))
+	}
+
+	// slug, title, число тредов
+	for _, f := range []struct {
+		slug, title string
+		threads     int
+	}{{"b", "Zeta", 1}, {"A", "alpha", 3}, {"c", "mu", 0}, {"d", "beta", 1}, {"e", "MU", 0}} {
+		expect(t, server, "POST", "/forum/create", jsonBody(t, &models.Forum{Slug: f.slug, Title: f.title, Owner: "owner"}), http.StatusCreated, nil)
+		for i := 0; i < f.threads; i++ {
+			createThread(t, server, f.slug, "owner", "")
+		}
+	}
+	createPosts(t, server, "1", models.Posts{{Author: "owner", Message: "p"}, {Author: "owner", Message: "p"}})
+
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{
+		{"limit=10", "A,b,c,d,e"},
+		{"limit=2", "A,b"},
+		{"limit=10&since=b", "c,d,e"},
+		{"limit=2&since=C&desc=true", "b,A"},
+		{"limit=10&sort=title", "A,d,c,e,b"},
+		{"limit=10&sort=title&since=c", "e,b"},
+		{"limit=10&sort=title&desc=true&since=c", "d,A"},
+		{"limit=10&sort=threads", "c,e,b,d,A"},
+		{"limit=2&sort=threads&since=b", "d,A"},
+		{"limit=10&sort=threads&desc=true", "A,d,b,e,c"},
+		{"limit=10&sort=posts&desc=true", "b,e,d,c,A"},
+	} {
+		forums := models.Forums{}
+		expect(t, server, "GET", "/forums?"+tc.query, "", http.StatusOK, &forums)
+		if got := forumSlugs(forums); got != tc.want {
+			t.Errorf("GET /forums?%s = %s, want %s", tc.query, got, tc.want)
+		}
+	}
+
+	expect(t, server, "GET", "/forums?limit=1&sort=posts&desc=true", "", http.StatusOK, &forums)
+	if len(forums) != 1 || forums[0].Posts != 2 || forums[0].Threads != 1 || forums[0].Title != "Zeta" {
+		t.Errorf("top forum by posts = %+v", forums)
+	}
+
+	expectMessage(t, server, "GET", "/forums?sort=users", "", http.StatusBadRequest)
+	expectMessage(t, server, "GET", "/forums?desc=yes", "", http.StatusBadRequest)
+	expectMessage(t, server, "GET", "/forums?limit=-1", "", http.StatusBadRequest)
+}
+
+func TestSubForums(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "owner")
+	createForum(t, server, "root", "owner")
+	for _, f := range []struct{ slug, parent string }{{"mid", "ROOT"}, {"leaf", "mid"}, {"other", "root"}} {
+		forum := &models.Forum{}
+		expect(t, server, "POST", "/forum/create", jsonBody(t, &models.Forum{Slug: f.slug, Title: "Forum " + f.slug, Owner: "owner", Parent: f.parent}), http.StatusCreated, forum)
+		if !strings.EqualFold(forum.Parent, f.parent) {
+			t.Errorf("created sub-forum = %+v", forum)
+		}
+	}
+	expectMessage(t, server, "POST", "/forum/create", jsonBody(t, &models.Forum{Slug: "orphan", Title: "t", Owner: "owner", Parent: "missing"}), http.StatusNotFound)
+	expectMessage(t, server, "POST", "/forum/create", jsonBody(t, &models.Forum{Slug: "loop", Title: "t", Owner: "owner", Parent: "LOOP"}), http.StatusBadRequest)
+
+	children := models.Forums{}
+	expect(t, server, "GET", "/forum/root/children", "", http.StatusOK, &children)
+	if got := forumSlugs(children); got != "mid,other" {
+		t.Errorf("children of root = %s", got)
+	}
+	expect(t, server, "GET", "/forum/leaf/children", "", http.StatusOK, &children)
+	if len(children) != 0 {
+		t.Errorf("children of leaf = %s", forumSlugs(children))
+	}
+	expectMessage(t, server, "GET", "/forum/missing/children", "", http.StatusNotFound)
+
+	details := &models.Forum{}
+	expect(t, server, "GET", "/forum/LEAF/details", "", http.StatusOK, details)
+	want := []models.ForumCrumb{{Slug: "root", Title: "Forum root"}, {Slug: "mid", Title: "Forum mid"}}
+	if details.Parent != "mid" || fmt.Sprint(details.Breadcrumbs) != fmt.Sprint(want) {
+		t.Errorf("leaf details = %+v", details)
+	}
+	details = &models.Forum{}
+	expect(t, server, "GET", "/forum/root/details", "", http.StatusOK, details)
+	if details.Parent != "" || len(details.Breadcrumbs) != 0 {
+		t.Errorf("root details = %+v", details)
+	}
+
+	createThread(t, server, "leaf", "owner", "deep")
+	createThread(t, server, "other", "owner", "side")
+	createPosts(t, server, "deep", models.Posts{{Author: "owner", Message: "a"}, {Author: "owner", Message: "b"}})
+	createPosts(t, server, "side", models.Posts{{Author: "owner", Message: "c"}})
+
+	counters := func(stage string, want map[string][2]int64) {
+		t.Helper()
+		for slug, counts := range want {
+			forum := &models.Forum{}
+			expect(t, server, "GET", "/forum/"+slug+"/details", "", http.StatusOK, forum)
+			if int64(forum.Threads) != counts[0] || forum.Posts != counts[1] {
+				t.Errorf("%s: %s threads=%d posts=%d, want %v", stage, slug, forum.Threads, forum.Posts, counts)
+			}
+		}
+	}
+	counters("after create", map[string][2]int64{"root": {2, 3}, "mid": {1, 2}, "leaf": {1, 2}, "other": {1, 1}})
+
+	posts := models.Posts{}
+	expect(t, server, "GET", "/thread/deep/posts?limit=1", "", http.StatusOK, &posts)
+	owner := tokenFor("owner")
+	expectAs(t, server, owner, "DELETE", fmt.Sprintf("/post/%d", posts[0].ID), "", http.StatusOK, nil)
+	counters("after post delete", map[string][2]int64{"root": {2, 2}, "mid": {1, 1}, "leaf": {1, 1}})
+
+	expectAs(t, server, owner, "DELETE", "/thread/side", "", http.StatusOK, nil)
+	counters("after thread delete", map[string][2]int64{"root": {1, 1}, "other": {0, 0}})
+
+	expectMessageAs(t, server, owner, "DELETE", "/forum/mid", "", http.StatusConflict)
+	expectAs(t, server, owner, "POST", "/forum/mid/details", `{"slug": "middle"}`, http.StatusOK, nil)
+	expect(t, server, "GET", "/forum/leaf/details", "", http.StatusOK, details)
+	if details.Parent != "middle" || len(details.Breadcrumbs) != 2 || details.Breadcrumbs[1].Slug != "middle" {
+		t.Errorf("leaf after parent rename = %+v", details)
+	}
+
+	expectAs(t, server, owner, "DELETE", "/forum/leaf", "", http.StatusOK, nil)
+	counters("after forum delete", map[string][2]int64{"root": {0, 0}, "middle": {0, 0}})
+	expectAs(t, server, owner, "DELETE", "/forum/middle", "", http.StatusOK, nil)
+}
+
+func TestUpdateForum(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "owner")
+	createUser(t, server, "heir")
+	createUser(t, server, "writer")
+	createForum(t, server, "old", "owner")
+	createForum(t, server, "busy", "owner")
+	thread := createThread(t, server, "old", "writer", "topic")
+	createPosts(t, server, "topic", models.Posts{{Author: "writer", Message: "hi"}})
+
+	owner := tokenFor("owner")
+	expectMessage(t, server, "POST", "/forum/old/details", `{}`, http.StatusUnauthorized)
+	unchanged := &models.Forum{}
+	expectAs(t, server, owner, "POST", "/forum/old/details", `{}`, http.StatusOK, unchanged)
+	if unchanged.Slug != "old" || unchanged.Owner != "owner" {
+		t.Errorf("empty update = %+v", unchanged)
+	}
+
+	updated := &models.Forum{}
+	expectAs(t, server, owner, "POST", "/forum/old/details", `{"title": "Renamed", "user": "HEIR", "slug": "new"}`, http.StatusOK, updated)
+	if updated.Slug != "new" || updated.Title != "Renamed" || updated.Owner != "heir" || updated.Threads != 1 || updated.Posts != 1 {
+		t.Errorf("updated forum = %+v", updated)
+	}
+	expectMessage(t, server, "GET", "/forum/old/details", "", http.StatusNotFound)
+
+	moved := &models.Thread{}
+	expect(t, server, "GET", fmt.Sprintf("/thread/%d/details", thread.ID), "", http.StatusOK, moved)
+	if moved.Forum != "new" {
+		t.Errorf("thread forum after rename = %q", moved.Forum)
+	}
+	posts := models.Posts{}
+	expect(t, server, "GET", "/thread/topic/posts?limit=10", "", http.StatusOK, &posts)
+	if len(posts) != 1 || posts[0].Forum != "new" {
+		t.Errorf("posts after rename = %+v", posts)
+	}
+	users := models.Users{}
+	expect(t, server, "GET", "/forum/new/users?limit=10", "", http.StatusOK, &users)
+	if got := nicknames(users); got != "writer" {
+		t.Errorf("forum users after rename = %s", got)
+	}
+	threads := models.Threads{}
+	expect(t, server, "GET", "/forum/new/threads?limit=10", "", http.StatusOK, &threads)
+	if got := threadSlugs(threads); fmt.Sprint(got) != "[topic]" {
+		t.Errorf("forum threads after rename = %v", got)
+	}
+
+	conflict := &models.Forum{}
+	heir := tokenFor("heir")
+	expectAs(t, server, heir, "POST", "/forum/new/details", `{"slug": "BUSY"}`, http.StatusConflict, conflict)
+	if conflict.Slug != "busy" {
+		t.Errorf("conflict forum = %+v", conflict)
+	}
+	expectMessageAs(t, server, heir, "POST", "/forum/new/details", `{"user": "nobody"}`, http.StatusNotFound)
+	expectMessageAs(t, server, heir, "POST", "/forum/missing/details", `{"title": "x"}`, http.StatusNotFound)
+}
+
+func TestDeleteForum(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "owner")
+	for _, slug := range []string{"gone", "kept"} {
+		createForum(t, server, slug, "owner")
+		createThread(t, server, slug, "owner", slug+"-1")
+		createThread(t, server, slug, "owner", slug+"-2")
+		createPosts(t, server, slug+"-1", models.Posts{{Author: "owner", Message: "a"}, {Author: "owner", Message: "b"}})
+		expect(t, server, "POST", "/thread/"+slug+"-1/vote", `{"nickname": "owner", "voice": 1}`, http.StatusOK, nil)
+	}
+
+	expect(t, server, "POST", "/forum/create", jsonBody(t, &models.Forum{Slug: "kept-sub", Title: "sub", Owner: "owner", Parent: "kept"}), http.StatusCreated, nil)
+
+	owner := tokenFor("owner")
+	archived := &models.ForumDeleted{}
+	expectAs(t, server, owner, "DELETE", "/forum/kept?archive=true", "", http.StatusOK, archived)
+	if *archived != (models.ForumDeleted{Forum: "kept", Archived: true, Threads: 2, Posts: 2}) {
+		t.Errorf("archive result = %+v", archived)
+	}
+	for _, slug := range []string{"kept", "kept-sub"} {
+		forum := &models.Forum{}
+		expect(t, server, "GET", "/forum/"+slug+"/details", "", http.StatusOK, forum)
+		if !forum.Archived {
+			t.Errorf("archived forum = %+v", forum)
+		}
+	}
+	// архивный форум с подфорумами только для чтения, но ничего не скрывается
+	threads := models.Threads{}
+	expect(t, server, "GET", "/forum/kept/threads?limit=10", "", http.StatusOK, &threads)
+	if got := threadSlugs(threads); fmt.Sprint(got) != "[kept-1 kept-2]" || threads[0].Deleted || threads[0].Title == models.DeletedMessage {
+		t.Errorf("threads of archived forum = %+v", threads)
+	}
+	posts := models.Posts{}
+	expect(t, server, "GET", "/thread/kept-1/posts?limit=10", "", http.StatusOK, &posts)
+	if len(posts) != 2 || posts[0].Deleted || posts[0].Message != "a" {
+		t.Errorf("posts of archived forum = %+v", posts)
+	}
+	expectMessage(t, server, "POST", "/forum/kept/create", jsonBody(t, &models.Thread{Author: "owner", Title: "late", Message: "m"}), http.StatusConflict)
+	expectMessage(t, server, "POST", "/forum/kept-sub/create", jsonBody(t, &models.Thread{Author: "owner", Title: "late", Message: "m"}), http.StatusConflict)
+	expectMessage(t, server, "POST", "/thread/kept-1/create", `[{"author": "owner", "message": "late"}]`, http.StatusConflict)
+
+	removed := &models.ForumDeleted{}
+	expectAs(t, server, owner, "DELETE", "/forum/GONE", "", http.StatusOK, removed)
+	if *removed != (models.ForumDeleted{Forum: "gone", Threads: 2, Posts: 2}) {
+		t.Errorf("delete result = %+v", removed)
+	}
+	expectMessage(t, server, "GET", "/forum/gone/details", "", http.StatusNotFound)
+	expectMessage(t, server, "GET", "/thread/gone-1/details", "", http.StatusNotFound)
+	expectMessageAs(t, server, owner, "DELETE", "/forum/gone", "", http.StatusNotFound)
+	expectMessageAs(t, server, owner, "DELETE", "/forum/kept?archive=maybe", "", http.StatusBadRequest)
+
+	status := &models.Status{}
+	expect(t, server, "GET", "/service/status", "", http.StatusOK, status)
+	if status.Forum != 2 || status.Thread != 2 || status.Post != 2 {
+		t.Errorf("status after delete = %+v", status)
+	}
+}
+
+func TestThreadRoutes(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "author")
+	createUser(t, server, "voter")
+	createForum(t, server, "news", "author")
+
+	thread := createThread(t, server, "NEWS", "author", "first")
+	if thread.ID == 0 || thread.Forum != "news" || thread.Slug != "first" {
+		t.Errorf("created thread = %+v", thread)
+	}
+
+	existing := &models.Thread{}
+	expect(t, server, "POST", "/forum/news/create", jsonBody(t, &models.Thread{Author: "author", Title: "dup", Message: "m", Slug: "FIRST"}), http.StatusConflict, existing)
+	if existing.ID != thread.ID {
+		t.Errorf("conflict thread = %+v, want id %d", existing, thread.ID)
+	}
+	expectMessage(t, server, "POST", "/forum/missing/create", jsonBody(t, &models.Thread{Author: "author", Title: "t", Message: "m"}), http.StatusNotFound)
+	expectMessage(t, server, "POST", "/forum/news/create", jsonBody(t, &models.Thread{Author: "nobody", Title: "t", Message: "m"}), http.StatusNotFound)
+
+	byID := &models.Thread{}
+	expect(t, server, "GET", fmt.Sprintf("/thread/%d/details", thread.ID), "", http.StatusOK, byID)
+	bySlug := &models.Thread{}
+	expect(t, server, "GET", "/thread/First/details", "", http.StatusOK, bySlug)
+	if byID.ID != thread.ID || bySlug.ID != thread.ID {
+		t.Errorf("details by id %+v and slug %+v, want id %d", byID, bySlug, thread.ID)
+	}
+	expectMessage(t, server, "GET", "/thread/missing/details", "", http.StatusNotFound)
+	expectMessage(t, server, "GET", "/thread/100500/details", "", http.StatusNotFound)
+
+	updated := &models.Thread{}
+	expectMessageAs(t, server, tokenFor("voter"), "POST", "/thread/first/details", `{"title": "renamed"}`, http.StatusForbidden)
+	expectAs(t, server, tokenFor("author"), "POST", "/thread/first/details", `{"title": "renamed"}`, http.StatusOK, updated)
+	if updated.Title != "renamed" || updated.Message != thread.Message {
+		t.Errorf("updated thread = %+v", updated)
+	}
+	expectMessageAs(t, server, tokenFor("author"), "POST", "/thread/missing/details", `{"title": "x"}`, http.StatusNotFound)
+
+	voted := &models.Thread{}
+	expect(t, server, "POST", "/thread/first/vote", `{"nickname": "voter", "voice": 1}`, http.StatusOK, voted)
+	if voted.Votes != 1 {
+		t.Errorf("votes after first vote = %d, want 1", voted.Votes)
+	}
+	expect(t, server, "POST", fmt.Sprintf("/thread/%d/vote", thread.ID), `{"nickname": "author", "voice": 1}`, http.StatusOK, voted)
+	if voted.Votes != 2 {
+		t.Errorf("votes after second voter = %d, want 2", voted.Votes)
+	}
+	voted = &models.Thread{}
+	expect(t, server, "POST", "/thread/first/vote", `{"nickname": "VOTER", "voice": -1}`, http.StatusOK, voted)
+	if voted.Votes != 0 {
+		t.Errorf("votes after revote = %d, want 0", voted.Votes)
+	}
+	expectMessage(t, server, "POST", "/thread/missing/vote", `{"nickname": "voter", "voice": 1}`, http.StatusNotFound)
+	expectMessage(t, server, "POST", "/thread/first/vote", `{"nickname": "nobody", "voice": 1}`, http.StatusNotFound)
+}
+
+func TestVoteRetract(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "author")
+	createUser(t, server, "voter")
+	createForum(t, server, "news", "author")
+	createThread(t, server, "news", "author", "t")
+
+	for _, body := range []string{`{"nickname": "voter", "voice": 2}`, `{"nickname": "voter", "voice": 0}`, `{"nickname": "voter", "voice": -5}`} {
+		expectMessage(t, server, "POST", "/thread/t/vote", body, http.StatusBadRequest)
+	}
+
+	voted := &models.Thread{}
+	expect(t, server, "POST", "/thread/t/vote", `{"nickname": "voter", "voice": -1}`, http.StatusOK, voted)
+	if voted.Votes != -1 || voted.Voice == nil || *voted.Voice != -1 {
+		t.Errorf("after vote = %+v", voted)
+	}
+	expect(t, server, "POST", "/thread/t/vote", `{"nickname": "author", "voice": 1}`, http.StatusOK, nil)
+
+	retracted := &models.Thread{}
+	expect(t, server, "DELETE", "/thread/t/vote?nickname=VOTER", "", http.StatusOK, retracted)
+	if retracted.Votes != 1 || retracted.Voice == nil || *retracted.Voice != 0 {
+		t.Errorf("after retract = %+v", retracted)
+	}
+	details := &models.Thread{}
+	expect(t, server, "GET", "/thread/t/details", "", http.StatusOK, details)
+	if details.Votes != 1 || details.Voice != nil {
+		t.Errorf("details after retract = %+v", details)
+	}
+
+	expectMessage(t, server, "DELETE", "/thread/t/vote?nickname=voter", "", http.StatusNotFound)
+	expectMessage(t, server, "DELETE", "/thread/t/vote?nickname=nobody", "", http.StatusNotFound)
+	expectMessage(t, server, "DELETE", "/thread/missing/vote?nickname=voter", "", http.StatusNotFound)
+	expectMessage(t, server, "DELETE", "/thread/t/vote", "", http.StatusBadRequest)
+
+	// с токеном голосующий берётся из него
+	user := &models.User{Fullname: "Carol", Email: "carol@example.com", Password: "pw"}
+	expect(t, server, "POST", "/user/carol/create", jsonBody(t, user), http.StatusCreated, nil)
+	token := login(t, server, "carol", "pw")
+	expectAs(t, server, token, "POST", "/thread/t/vote", `{"voice": 1}`, http.StatusOK, voted)
+	if voted.Votes != 2 {
+		t.Errorf("votes after carol = %d, want 2", voted.Votes)
+	}
+	expectAs(t, server, token, "DELETE", "/thread/t/vote?nickname=author", "", http.StatusForbidden, nil)
+	expectAs(t, server, token, "DELETE", "/thread/t/vote", "", http.StatusOK, retracted)
+	if retracted.Votes != 1 {
+		t.Errorf("votes after carol retract = %d, want 1", retracted.Votes)
+	}
+}
+
+func TestPostVotes(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "author")
+	createUser(t, server, "voter")
+	createForum(t, server, "news", "author")
+	createThread(t, server, "news", "author", "t")
+	posts := createPosts(t, server, "t", models.Posts{
+		{Author: "author", Message: "first"},
+		{Author: "author", Message: "second"},
+	})
+	first, second := fmt.Sprint(posts[0].ID), fmt.Sprint(posts[1].ID)
+
+	expectMessage(t, server, "POST", "/post/"+first+"/vote", `{"nickname": "voter", "voice": 2}`, http.StatusBadRequest)
+	expectMessage(t, server, "POST", "/post/999999/vote", `{"nickname": "voter", "voice": 1}`, http.StatusNotFound)
+	expectMessage(t, server, "POST", "/post/"+first+"/vote", `{"nickname": "nobody", "voice": 1}`, http.StatusNotFound)
+
+	voted := &models.Post{}
+	expect(t, server, "POST", "/post/"+first+"/vote", `{"nickname": "voter", "voice": 1}`, http.StatusOK, voted)
+	if voted.Votes != 1 || voted.Voice == nil || *voted.Voice != 1 {
+		t.Errorf("after vote = %+v", voted)
+	}
+	// повторный голос заменяет прежний
+	expect(t, server, "POST", "/post/"+first+"/vote", `{"nickname": "VOTER", "voice": -1}`, http.StatusOK, voted)
+	if voted.Votes != -1 {
+		t.Errorf("after revote votes = %d, want -1", voted.Votes)
+	}
+	expect(t, server, "POST", "/post/"+first+"/vote", `{"nickname": "author", "voice": 1}`, http.StatusOK, nil)
+	expect(t, server, "POST", "/post/"+second+"/vote", `{"nickname": "voter", "voice": 1}`, http.StatusOK, nil)
+	expect(t, server, "POST", "/thread/t/vote", `{"nickname": "voter", "voice": 1}`, http.StatusOK, nil)
+
+	for _, path := range []string{"/thread/t/posts?limit=10&sort=flat", "/thread/t/posts?limit=10&sort=tree", "/user/author/posts?limit=10"} {
+		listed := models.Posts{}
+		expect(t, server, "GET", path, "", http.StatusOK, &listed)
+		if len(listed) != 2 || listed[0].Votes != 0 || listed[1].Votes != 1 || listed[0].Voice != nil {
+			t.Errorf("%s = %+v", path, listed)
+		}
+	}
+	details := &models.PostFull{}
+	expect(t, server, "GET", "/post/"+second+"/details", "", http.StatusOK, details)
+	if details.Post.Votes != 1 {
+		t.Errorf("details votes = %d, want 1", details.Post.Votes)
+	}
+
+	// тред 1, первый пост 0, второй пост 1
+	profile := &models.User{}
+	expect(t, server, "GET", "/user/author/profile", "", http.StatusOK, profile)
+	if profile.Karma != 2 {
+		t.Errorf("karma = %d, want 2", profile.Karma)
+	}
+	voter := &models.User{}
+	expect(t, server, "GET", "/user/voter/profile", "", http.StatusOK, voter)
+	if voter.Karma != 0 {
+		t.Errorf("voter karma = %d, want 0", voter.Karma)
+	}
+	members := models.Users{}
+	expect(t, server, "GET", "/forum/news/users?limit=10", "", http.StatusOK, &members)
+	if len(members) != 1 || members[0].Karma != 2 {
+		t.Errorf("forum users = %+v, want author with karma 2", members)
+	}
+	// нулевые голоса и карма не пропадают из ответа
+	raw := map[string]json.RawMessage{}
+	expect(t, server, "GET", "/user/voter/profile", "", http.StatusOK, &raw)
+	if string(raw["karma"]) != "0" {
+		t.Errorf("voter profile karma = %s, want 0", raw["karma"])
+	}
+	raw = map[string]json.RawMessage{}
+	expect(t, server, "GET", "/post/"+first+"/details", "", http.StatusOK, &raw)
+	if !strings.Contains(string(raw["post"]), `"votes":0`) {
+		t.Errorf("post details = %s, want votes 0", raw["post"])
+	}
+
+	expectAs(t, server, tokenFor("author"), "DELETE", "/post/"+second, "", http.StatusOK, nil)
+	expect(t, server, "DELETE", "/thread/t/vote?nickname=voter", "", http.StatusOK, nil)
+	profile = &models.User{}
+	expect(t, server, "GET", "/user/author/profile", "", http.StatusOK, profile)
+	if profile.Karma != 0 {
+		t.Errorf("karma after delete and retract = %d, want 0", profile.Karma)
+	}
+
+	// карма из запроса на создание игнорируется
+	created := &models.User{}
+	expect(t, server, "POST", "/user/carol/create", `{"fullname": "Carol", "email": "carol@example.com", "karma": 100}`, http.StatusCreated, created)
+	if created.Karma != 0 {
+		t.Errorf("created karma = %d, want 0", created.Karma)
+	}
+}
+
+func TestPatchThread(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "author")
+	createForum(t, server, "patch", "author")
+	taken := createThread(t, server, "patch", "author", "taken")
+	noSlug := createThread(t, server, "patch", "author", "")
+	path := fmt.Sprintf("/thread/%d/details", noSlug.ID)
+	author := tokenFor("author")
+
+	// автор треда правит свой тред, но не чужой
+	createUser(t, server, "writer")
+	own := createThread(t, server, "patch", "writer", "own")
+	expectMessage(t, server, "PATCH", "/thread/own/details", `{"title": "x"}`, http.StatusUnauthorized)
+	expectAs(t, server, tokenFor("writer"), "PATCH", "/thread/own/details", `{"title": "mine"}`, http.StatusOK, own)
+	if own.Title != "mine" {
+		t.Errorf("thread patched by author = %+v", own)
+	}
+	expectMessageAs(t, server, tokenFor("writer"), "PATCH", path, `{"title": "x"}`, http.StatusForbidden)
+
+	unchanged := &models.Thread{}
+	expectAs(t, server, author, "PATCH", path, `{}`, http.StatusOK, unchanged)
+	if unchanged.ID != noSlug.ID || unchanged.Title != noSlug.Title || unchanged.Message != noSlug.Message {
+		t.Errorf("empty patch = %+v, want %+v", unchanged, noSlug)
+	}
+
+	patched := &models.Thread{}
+	expectAs(t, server, author, "PATCH", path, `{"message": "new message"}`, http.StatusOK, patched)
+	if patched.ID != noSlug.ID || patched.Message != "new message" || patched.Title != noSlug.Title || patched.Slug != "" {
+		t.Errorf("message patch of thread without slug = %+v", patched)
+	}
+
+	patched = &models.Thread{}
+	expectAs(t, server, author, "PATCH", path, `{"slug": "named", "title": "named"}`, http.StatusOK, patched)
+	if patched.ID != noSlug.ID || patched.Slug != "named" || patched.Title != "named" || patched.Message != "new message" {
+		t.Errorf("slug patch = %+v", patched)
+	}
+	bySlug := &models.Thread{}
+	expect(t, server, "GET", "/thread/named/details", "", http.StatusOK, bySlug)
+	if bySlug.ID != noSlug.ID {
+		t.Errorf("thread by new slug = %+v", bySlug)
+	}
+
+	conflict := &models.Thread{}
+	expectAs(t, server, author, "PATCH", "/thread/named/details", `{"slug": "TAKEN"}`, http.StatusConflict, conflict)
+	if conflict.ID != taken.ID {
+		t.Errorf("conflict thread = %+v, want id %d", conflict, taken.ID)
+	}
+
+	renamed := &models.Thread{}
+	expectAs(t, server, author, "PATCH", "/thread/taken/details", `{"slug": "free"}`, http.StatusOK, renamed)
+	if renamed.ID != taken.ID || renamed.Slug != "free" {
+		t.Errorf("renamed thread = %+v", renamed)
+	}
+	expectMessage(t, server, "GET", "/thread/taken/details", "", http.StatusNotFound)
+	expectAs(t, server, author, "PATCH", "/thread/named/details", `{"slug": "taken"}`, http.StatusOK, &models.Thread{})
+	expectMessageAs(t, server, author, "PATCH", "/thread/missing/details", `{"slug": "x"}`, http.StatusNotFound)
+	expectMessageAs(t, server, author, "PATCH", "/thread/named/details", `{"slug": "42"}`, http.StatusBadRequest)
+}
+
+func TestPostRoutes(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "writer")
+	forum := createForum(t, server, "talk", "writer")
+	thread := createThread(t, server, "talk", "writer", "chat")
+	createThread(t, server, "talk", "writer", "other")
+
+	roots := createPosts(t, server, "chat", models.Posts{
+		{Author: "writer", Message: "root 1"},
+		{Author: "WRITER", Message: "root 2"},
+	})
+	for _, post := range roots {
+		if post.Thread != thread.ID || post.Forum != forum.Slug || !strings.EqualFold(post.Author, "writer") {
+			t.Errorf("created post = %+v", post)
+		}
+	}
+	replies := createPosts(t, server, fmt.Sprintf("%d", thread.ID), models.Posts{
+		{Author: "writer", Message: "reply 1.1", Parent: roots[0].ID},
+		{Author: "writer", Message: "reply 2.1", Parent: roots[1].ID},
+	})
+	nested := createPosts(t, server, "chat", models.Posts{
+		{Author: "writer", Message: "reply 1.1.1", Parent: replies[0].ID},
+	})
+
+	empty := models.Posts{}
+	expect(t, server, "POST", "/thread/chat/create", "[]", http.StatusCreated, &empty)
+	if len(empty) != 0 {
+		t.Errorf("empty batch returned %d posts", len(empty))
+	}
+	expectMessage(t, server, "POST", "/thread/missing/create", jsonBody(t, models.Posts{{Author: "writer", Message: "m"}}), http.StatusNotFound)
+	expectMessage(t, server, "POST", "/thread/chat/create", jsonBody(t, models.Posts{{Author: "nobody", Message: "m"}}), http.StatusNotFound)
+	expectMessage(t, server, "POST", "/thread/other/create", jsonBody(t, models.Posts{{Author: "writer", Message: "m", Parent: roots[0].ID}}), http.StatusConflict)
+	expectMessage(t, server, "POST", "/thread/chat/create", jsonBody(t, models.Posts{{Author: "writer", Message: "m", Parent: 100500}}), http.StatusConflict)
+
+	r1, r2, r11, r21, r111 := roots[0].ID, roots[1].ID, replies[0].ID, replies[1].ID, nested[0].ID
+	cases := []struct {
+		query string
+		want  []int64
+	}{
+		{"sort=flat&limit=10", []int64{r1, r2, r11, r21, r111}},
+		{"sort=flat&limit=2&desc=true", []int64{r111, r21}},
+		{fmt.Sprintf("sort=flat&limit=10&since=%d", r2), []int64{r11, r21, r111}},
+		{"sort=tree&limit=10", []int64{r1, r11, r111, r2, r21}},
+		{"sort=tree&limit=10&desc=true", []int64{r21, r2, r111, r11, r1}},
+		{fmt.Sprintf("sort=tree&limit=2&since=%d", r11), []int64{r111, r2}},
+		{"sort=parent_tree&limit=1", []int64{r1, r11, r111}},
+		{"sort=parent_tree&limit=10&desc=true", []int64{r2, r21, r1, r11, r111}},
+		{fmt.Sprintf("sort=parent_tree&limit=10&since=%d", r1), []int64{r2, r21}},
+	}
+	for _, c := range cases {
+		posts := models.Posts{}
+		expect(t, server, "GET", "/thread/chat/posts?"+c.query, "", http.StatusOK, &posts)
+		if got := postIDs(posts); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("posts?%s = %v, want %v", c.query, got, c.want)
+		}
+	}
+	expectMessage(t, server, "GET", "/thread/missing/posts?limit=10", "", http.StatusNotFound)
+
+	full := &models.PostFull{}
+	expect(t, server, "GET", fmt.Sprintf("/post/%d/details?related=user,forum,thread", r11), "", http.StatusOK, full)
+	if full.Post == nil || full.Post.ID != r11 || full.Post.Parent != r1 {
+		t.Fatalf("post details = %+v", full.Post)
+	}
+	if full.Author == nil || full.Author.Nickname != "writer" {
+		t.Errorf("related author = %+v", full.Author)
+	}
+	if full.Forum == nil || full.Forum.Slug != "talk" || full.Forum.Posts != 5 {
+		t.Errorf("related forum = %+v", full.Forum)
+	}
+	if full.Thread == nil || full.Thread.ID != thread.ID {
+		t.Errorf("related thread = %+v", full.Thread)
+	}
+	plain := map[string]json.RawMessage{}
+	expect(t, server, "GET", fmt.Sprintf("/post/%d/details", r1), "", http.StatusOK, &plain)
+	for _, field := range []string{"author", "forum", "thread"} {
+		if value, ok := plain[field]; ok && string(value) != "null" {
+			t.Errorf("post details without related contains %s", field)
+		}
+	}
+	expectMessage(t, server, "GET", "/post/100500/details", "", http.StatusNotFound)
+
+	writer := tokenFor("writer")
+	edited := &models.Post{}
+	expectAs(t, server, writer, "POST", fmt.Sprintf("/post/%d/details", r1), `{"message": "root 1"}`, http.StatusOK, edited)
+	if edited.IsEdited {
+		t.Errorf("same message marked as edited: %+v", edited)
+	}
+	expectAs(t, server, writer, "POST", fmt.Sprintf("/post/%d/details", r1), `{"message": "root 1 edited"}`, http.StatusOK, edited)
+	if !edited.IsEdited || edited.Message != "root 1 edited" {
+		t.Errorf("edited post = %+v", edited)
+	}
+	expectAs(t, server, writer, "POST", fmt.Sprintf("/post/%d/details", r1), `{}`, http.StatusOK, edited)
+	if !edited.IsEdited || edited.Message != "root 1 edited" {
+		t.Errorf("post after empty update = %+v", edited)
+	}
+	expectMessageAs(t, server, writer, "POST", "/post/100500/details", `{"message": "x"}`, http.StatusNotFound)
+}
+
+func TestDeleteThread(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "author")
+	createForum(t, server, "del", "author")
+	doomed := createThread(t, server, "del", "author", "doomed")
+	kept := createThread(t, server, "del", "author", "kept")
+	posts := createPosts(t, server, "doomed", models.Posts{{Author: "author", Message: "a"}, {Author: "author", Message: "b"}})
+	createPosts(t, server, "kept", models.Posts{{Author: "author", Message: "c"}})
+	expect(t, server, "POST", "/thread/doomed/vote", `{"nickname": "author", "voice": 1}`, http.StatusOK, nil)
+
+	author := tokenFor("author")
+	expectMessage(t, server, "DELETE", "/thread/DOOMED", "", http.StatusUnauthorized)
+	deleted := &models.Thread{}
+	expectAs(t, server, author, "DELETE", "/thread/DOOMED", "", http.StatusOK, deleted)
+	if deleted.ID != doomed.ID {
+		t.Errorf("deleted thread = %+v, want id %d", deleted, doomed.ID)
+	}
+	expectMessage(t, server, "GET", "/thread/doomed/details", "", http.StatusNotFound)
+	expectMessage(t, server, "GET", fmt.Sprintf("/post/%d/details", posts[0].ID), "", http.StatusNotFound)
+	expectMessageAs(t, server, author, "DELETE", "/thread/doomed", "", http.StatusNotFound)
+	expectMessageAs(t, server, author, "DELETE", fmt.Sprintf("/thread/%d", doomed.ID), "", http.StatusNotFound)
+
+	forum := &models.Forum{}
+	expect(t, server, "GET", "/forum/del/details", "", http.StatusOK, forum)
+	if forum.Threads != 1 || forum.Posts != 1 {
+		t.Errorf("forum counters threads=%d posts=%d, want 1 and 1", forum.Threads, forum.Posts)
+	}
+	expect(t, server, "GET", fmt.Sprintf("/thread/%d/details", kept.ID), "", http.StatusOK, nil)
+
+	// slug освободился вместе с голосами
+	again := createThread(t, server, "del", "author", "doomed")
+	voted := &models.Thread{}
+	expect(t, server, "POST", "/thread/doomed/vote", `{"nickname": "author", "voice": -1}`, http.StatusOK, voted)
+	if voted.ID != again.ID || voted.Votes != -1 {
+		t.Errorf("vote in recreated thread = %+v, want votes -1", voted)
+	}
+}
+
+func TestDeletePost(t *testing.T) {
+	cases := []struct {
+		children string
+		// посты, оставшиеся в дереве после удаления r1, и сколько постов осталось на форуме
+		tree   func(r1, r11, r111, r2 int64) []int64
+		parent func(r1, r11 int64) int64
+		posts  int64
+	}{
+		{
+			children: config.DeleteReparent,
+			// r11 стал корневым и встал после r2 по path
+			tree:   func(r1, r11, r111, r2 int64) []int64 { return []int64{r2, r11, r111} },
+			parent: func(r1, r11 int64) int64 { return 0 },
+			posts:  3,
+		},
+		{
+			children: config.DeleteTombstone,
+			tree:     func(r1, r11, r111, r2 int64) []int64 { return []int64{r1, r11, r111, r2} },
+			parent:   func(r1, r11 int64) int64 { return r1 },
+			posts:    4,
+		},
+		{
+			children: config.DeleteCascade,
+			tree:     func(r1, r11, r111, r2 int64) []int64 { return []int64{r2} },
+			posts:    1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.children, func(t *testing.T) {
+			server := testServer(t, func(cfg *config.Config) { cfg.DeleteChildren = c.children })
+			defer server.Close()
+
+			createUser(t, server, "author")
+			createForum(t, server, "del", "author")
+			createThread(t, server, "del", "author", "chat")
+			roots := createPosts(t, server, "chat", models.Posts{{Author: "author", Message: "r1"}, {Author: "author", Message: "r2"}})
+			r1, r2 := roots[0].ID, roots[1].ID
+			r11 := createPosts(t, server, "chat", models.Posts{{Author: "author", Message: "r11", Parent: r1}})[0].ID
+			r111 := createPosts(t, server, "chat", models.Posts{{Author: "author", Message: "r111", Parent: r11}})[0].ID
+
+			author := tokenFor("author")
+			deleted := &models.Post{}
+			expectAs(t, server, author, "DELETE", fmt.Sprintf("/post/%d", r1), "", http.StatusOK, deleted)
+			if deleted.ID != r1 {
+				t.Errorf("deleted post = %+v", deleted)
+			}
+			if tombstone := c.children == config.DeleteTombstone; tombstone != (deleted.Message == models.DeletedMessage) {
+				t.Errorf("deleted post message = %q", deleted.Message)
+			}
+			expectMessageAs(t, server, author, "DELETE", "/post/100500", "", http.StatusNotFound)
+
+			tree := models.Posts{}
+			expect(t, server, "GET", "/thread/chat/posts?sort=tree&limit=10", "", http.StatusOK, &tree)
+			if got, want := postIDs(tree), c.tree(r1, r11, r111, r2); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("tree after delete = %v, want %v", got, want)
+			}
+			if c.parent != nil {
+				reply := &models.PostFull{}
+				expect(t, server, "GET", fmt.Sprintf("/post/%d/details", r11), "", http.StatusOK, reply)
+				if want := c.parent(r1, r11); reply.Post.Parent != want {
+					t.Errorf("parent of reply = %d, want %d", reply.Post.Parent, want)
+				}
+				// в поддерево выжившего ответа можно писать дальше, path остался корректным
+				createPosts(t, server, "chat", models.Posts{{Author: "author", Message: "late", Parent: r111}})
+				expect(t, server, "GET", "/thread/chat/posts?sort=parent_tree&limit=10", "", http.StatusOK, &tree)
+				if len(tree) != int(c.posts)+1 {
+					t.Errorf("parent_tree after reply = %v", postIDs(tree))
+				}
+				c.posts++
+			}
+
+			forum := &models.Forum{}
+			expect(t, server, "GET", "/forum/del/details", "", http.StatusOK, forum)
+			if forum.Posts != c.posts {
+				t.Errorf("forum posts = %d, want %d", forum.Posts, c.posts)
+			}
+		})
+	}
+}
+
+func TestHideAndRestore(t *testing.T) {
+	server, repos := testServerRepos(t)
+	defer server.Close()
+
+	createUser(t, server, "author")
+	createUser(t, server, "moder")
+	createUser(t, server, "root")
+	if err := repos.roles.SetAdmin(context.Background(), "root", true); err != nil {
+		t.Fatal(err)
+	}
+	createForum(t, server, "mod", "author")
+	expectAs(t, server, tokenFor("author"), "PUT", "/forum/mod/moderators/moder", "", http.StatusOK, nil)
+	moder, root := tokenFor("moder"), tokenFor("root")
+	hidden := createThread(t, server, "mod", "author", "hidden")
+	createThread(t, server, "mod", "author", "visible")
+	roots := createPosts(t, server, "hidden", models.Posts{{Author: "author", Message: "root"}, {Author: "author", Message: "other"}})
+	reply := createPosts(t, server, "hidden", models.Posts{{Author: "author", Message: "reply", Parent: roots[0].ID}})[0]
+
+	expectMessage(t, server, "POST", fmt.Sprintf("/post/%d/hide", roots[0].ID), `{"nickname": "moder"}`, http.StatusUnauthorized)
+	post := &models.Post{}
+	expectAs(t, server, moder, "POST", fmt.Sprintf("/post/%d/hide", roots[0].ID), `{"nickname": "MODER"}`, http.StatusOK, post)
+	if !post.Deleted || post.DeletedBy != "moder" || post.DeletedAt == nil || post.Message != models.DeletedMessage {
+		t.Errorf("hidden post = %+v", post)
+	}
+	expectMessageAs(t, server, moder, "POST", "/post/100500/hide", `{"nickname": "moder"}`, http.StatusNotFound)
+	expectMessageAs(t, server, moder, "POST", fmt.Sprintf("/post/%d/hide", reply.ID), `{"nickname": "nobody"}`, http.StatusForbidden)
+
+	for _, sort := range []string{"tree", "parent_tree"} {
+		posts := models.Posts{}
+		expect(t, server, "GET", "/thread/hidden/posts?limit=10&sort="+sort, "", http.StatusOK, &posts)
+		if len(posts) != 3 || posts[0].ID != roots[0].ID || posts[0].Message != models.DeletedMessage || posts[1].ID != reply.ID {
+			t.Errorf("%s with tombstone = %+v", sort, posts)
+		}
+	}
+	flat := models.Posts{}
+	expect(t, server, "GET", "/thread/hidden/posts?limit=10&sort=flat", "", http.StatusOK, &flat)
+	if got := postIDs(flat); fmt.Sprint(got) != fmt.Sprint([]int64{roots[1].ID, reply.ID}) {
+		t.Errorf("flat skips hidden post: got %v", got)
+	}
+
+	thread := &models.Thread{}
+	expectAs(t, server, moder, "POST", "/thread/hidden/hide", `{}`, http.StatusOK, thread)
+	if !thread.Deleted || thread.ID != hidden.ID || thread.DeletedBy != "moder" || thread.Title != models.DeletedMessage {
+		t.Errorf("hidden thread = %+v", thread)
+	}
+	expectMessageAs(t, server, moder, "POST", "/thread/missing/hide", `{"nickname": "moder"}`, http.StatusNotFound)
+
+	threads := models.Threads{}
+	expect(t, server, "GET", "/forum/mod/threads?limit=10", "", http.StatusOK, &threads)
+	if got := threadSlugs(threads); fmt.Sprint(got) != "[visible]" {
+		t.Errorf("threads without hidden = %v", got)
+	}
+	expectMessage(t, server, "GET", "/forum/mod/threads?limit=10&deleted=true", "", http.StatusUnauthorized)
+	expectMessageAs(t, server, tokenFor("outsider"), "GET", "/forum/mod/threads?limit=10&deleted=true", "", http.StatusForbidden)
+	expectMessageAs(t, server, moder, "GET", "/forum/mod/threads?limit=10&deleted=maybe", "", http.StatusBadRequest)
+	expectAs(t, server, moder, "GET", "/forum/mod/threads?limit=10&deleted=true", "", http.StatusOK, &threads)
+	if len(threads) != 2 {
+		t.Errorf("threads with hidden = %v", threadSlugs(threads))
+	}
+
+	thread = &models.Thread{}
+	expectMessageAs(t, server, tokenFor("outsider"), "POST", "/admin/thread/hidden/restore", "", http.StatusForbidden)
+	expectAs(t, server, root, "POST", "/admin/thread/hidden/restore", "", http.StatusOK, thread)
+	if thread.Deleted || thread.DeletedBy != "" || thread.Title != hidden.Title {
+		t.Errorf("restored thread = %+v", thread)
+	}
+	expectMessageAs(t, server, root, "POST", "/admin/thread/missing/restore", "", http.StatusNotFound)
+
+	post = &models.Post{}
+	expectAs(t, server, root, "POST", fmt.Sprintf("/admin/post/%d/restore", roots[0].ID), "", http.StatusOK, post)
+	if post.Deleted || post.Message != "root" {
+		t.Errorf("restored post = %+v", post)
+	}
+	expectMessageAs(t, server, root, "POST", "/admin/post/100500/restore", "", http.StatusNotFound)
+	post = &models.Post{}
+	expectAs(t, server, root, "POST", fmt.Sprintf("/post/%d/hide", reply.ID), `{}`, http.StatusOK, post)
+	if !post.Deleted || post.DeletedBy != "root" {
+		t.Errorf("post hidden by caller = %+v", post)
+	}
+
+	expect(t, server, "GET", "/forum/mod/threads?limit=10", "", http.StatusOK, &threads)
+	if len(threads) != 2 {
+		t.Errorf("threads after restore = %v", threadSlugs(threads))
+	}
+}
+
+func TestPostHistory(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "author")
+	createUser(t, server, "moder")
+	createForum(t, server, "hist", "author")
+	expectAs(t, server, tokenFor("author"), "PUT", "/forum/hist/moderators/moder", "", http.StatusOK, nil)
+	createThread(t, server, "hist", "author", "edits")
+	post := createPosts(t, server, "edits", models.Posts{{Author: "author", Message: "hello old world"}})[0]
+	path := fmt.Sprintf("/post/%d", post.ID)
+
+	history := models.PostRevisions{}
+	expect(t, server, "GET", path+"/history", "", http.StatusOK, &history)
+	if len(history) != 1 || history[0].Revision != 1 || history[0].Message != "hello old world" {
+		t.Errorf("history before edits = %+v", history)
+	}
+
+	author, moder := tokenFor("author"), tokenFor("moder")
+	expectAs(t, server, author, "POST", path+"/details", `{"message": "hello new world"}`, http.StatusOK, &models.Post{})
+	expectAs(t, server, author, "POST", path+"/details", `{"message": "hello new world"}`, http.StatusOK, &models.Post{})
+	expectAs(t, server, moder, "POST", path+"/details", `{"message": "hello brave new world", "editor": "moder"}`, http.StatusOK, &models.Post{})
+	expectMessageAs(t, server, moder, "POST", path+"/details", `{"message": "x", "editor": "nobody"}`, http.StatusForbidden)
+
+	history = models.PostRevisions{}
+	expect(t, server, "GET", path+"/history", "", http.StatusOK, &history)
+	if len(history) != 3 {
+		t.Fatalf("history = %+v", history)
+	}
+	for i, want := range []struct {
+		message, editor string
+	}{{"hello old world", "author"}, {"hello new world", "author"}, {"hello brave new world", "moder"}} {
+		got := history[i]
+		if got.Revision != int32(i+1) || got.Message != want.message || !strings.EqualFold(got.Editor, want.editor) {
+			t.Errorf("revision %d = %+v", i+1, got)
+		}
+	}
+	expectMessage(t, server, "GET", "/post/100500/history", "", http.StatusNotFound)
+
+	diff := &models.PostDiff{}
+	expect(t, server, "GET", path+"/diff", "", http.StatusOK, diff)
+	want := []models.DiffChunk{
+		{Op: models.DiffEqual, Text: "hello "},
+		{Op: models.DiffDelete, Text: "old"},
+		{Op: models.DiffInsert, Text: "brave"},
+		{Op: models.DiffEqual, Text: " "},
+		{Op: models.DiffInsert, Text: "new "},
+		{Op: models.DiffEqual, Text: "world"},
+	}
+	if diff.From != 1 || diff.To != 3 || fmt.Sprint(diff.Changes) != fmt.Sprint(want) {
+		t.Errorf("diff 1..3 = %+v", diff)
+	}
+	diff = &models.PostDiff{}
+	expect(t, server, "GET", path+"/diff?from=2&to=3", "", http.StatusOK, diff)
+	if fmt.Sprint(diff.Changes) != fmt.Sprint([]models.DiffChunk{{Op: models.DiffEqual, Text: "hello "}, {Op: models.DiffInsert, Text: "brave "}, {Op: models.DiffEqual, Text: "new world"}}) {
+		t.Errorf("diff 2..3 = %+v", diff)
+	}
+	expectMessage(t, server, "GET", path+"/diff?to=7", "", http.StatusNotFound)
+	expectMessage(t, server, "GET", path+"/diff?from=one", "", http.StatusBadRequest)
+	expectMessage(t, server, "GET", "/post/100500/diff", "", http.StatusNotFound)
+}
+
+func searchHits(results models.SearchResults) string {
+	hits := make([]string, 0, len(results))
+	for _, result := range results {
+		hits = append(hits, fmt.Sprintf("%s%d", result.Type, result.ID))
+	}
+	return strings.Join(hits, ",")
+}
+
+func TestSearch(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	createUser(t, server, "alice")
+	createUser(t, server, "bob")
+	createForum(t, server, "go", "alice")
+	createForum(t, server, "rust", "alice")
+	threads := make([]string, 0, 2)
+	for i, thread := range []*models.Thread{
+		{Author: "alice", Title: "Gopher meetup", Message: "Bring your gopher", Slug: "meetup", Created: forumTime(0)},
+		{Author: "bob", Title: "Borrow checker", Message: "no gophers here", Slug: "borrow", Created: forumTime(1)},
+	} {
+		forum := []string{"go", "rust"}[i]
+		created := &models.Thread{}
+		expect(t, server, "POST", "/forum/"+forum+"/create", jsonBody(t, thread), http.StatusCreated, created)
+		threads = append(threads, fmt.Sprintf("thread%d", created.ID))
+	}
+	posts := createPosts(t, server, "meetup", models.Posts{
+		{Author: "bob", Message: "Gopher gopher GOPHER!", Created: forumTime(2)},
+		{Author: "alice", Message: "see you at the meetup", Created: forumTime(3)},
+	})
+	hidden := createPosts(t, server, "borrow", models.Posts{{Author: "bob", Message: "gopher in rust", Created: forumTime(4)}})[0]
+	expectAs(t, server, tokenFor("alice"), "POST", fmt.Sprintf("/post/%d/hide", hidden.ID), `{"nickname": "alice"}`, http.StatusOK, nil)
+
+	results := models.SearchResults{}
+	expect(t, server, "GET", "/search?q=gopher&limit=10", "", http.StatusOK, &results)
+	if got := searchHits(results); got != fmt.Sprintf("post%d,%s", posts[0].ID, threads[0]) {
+		t.Fatalf("search gopher = %s", got)
+	}
+	post, thread := results[0], results[1]
+	if fmt.Sprintf("thread%d", post.Thread) != threads[0] || post.Forum != "go" || !strings.EqualFold(post.Author, "bob") || post.Rank <= thread.Rank {
+		t.Errorf("post hit = %+v, thread hit = %+v", post, thread)
+	}
+	if post.Snippet != "<b>Gopher</b> <b>gopher</b> <b>GOPHER</b>!" || !strings.Contains(thread.Snippet, "<b>Gopher</b> meetup") {
+		t.Errorf("snippets %q and %q", post.Snippet, thread.Snippet)
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{
+		{"q=gopher", fmt.Sprintf("post%d", posts[0].ID)},
+		{"q=gopher&limit=10&offset=1", threads[0]},
+		{"q=gopher&limit=10&type=thread", threads[0]},
+		{"q=gopher+meetup&limit=10", threads[0]},
+		{"q=meetup&limit=10&type=post", fmt.Sprintf("post%d", posts[1].ID)},
+		{"q=meetup&limit=10&author=ALICE", fmt.Sprintf("post%d,%s", posts[1].ID, threads[0])},
+		{"q=gopher&limit=10&thread=meetup&type=post", fmt.Sprintf("post%d", posts[0].ID)},
+		{"q=gopher&limit=10&forum=rust", ""},
+		{"q=checker&limit=10&forum=RUST", threads[1]},
+		{"q=meetup&limit=10&since=" + forumTime(1).Format(time.RFC3339), fmt.Sprintf("post%d", posts[1].ID)},
+		{"q=meetup&limit=10&until=" + forumTime(1).Format(time.RFC3339), threads[0]},
+		{"q=nothing&limit=10", ""},
+	} {
+		results := models.SearchResults{}
+		expect(t, server, "GET", "/search?"+tc.query, "", http.StatusOK, &results)
+		if got := searchHits(results); got != tc.want {
+			t.Errorf("GET /search?%s = %s, want %s", tc.query, got, tc.want)
+		}
+	}
+
+	// посты скрытого треда тоже не ищутся
+	borrowed := createPosts(t, server, "borrow", models.Posts{{Author: "bob", Message: "gopher borrowed"}})[0]
+	expectAs(t, server, tokenFor("alice"), "POST", "/thread/borrow/hide", `{"nickname": "alice"}`, http.StatusOK, nil)
+	expect(t, server, "GET", "/search?q=borrowed&limit=10", "", http.StatusOK, &results)
+	if len(results) != 0 {
+		t.Errorf("search in hidden thread = %+v, post %d", results, borrowed.ID)
+	}
+	expectAs(t, server, tokenFor("alice"), "POST", "/admin/thread/borrow/restore", "", http.StatusOK, nil)
+
+	// разметка из сообщения в сниппет не попадает
+	createPosts(t, server, "borrow", models.Posts{{Author: "bob", Message: `<img src=x onerror="alert('quark')"> quark`}})
+	expect(t, server, "GET", "/search?q=quark&limit=10", "", http.StatusOK, &results)
+	if len(results) != 1 || strings.Contains(results[0].Snippet, "<img") || !strings.Contains(results[0].Snippet, "&lt;img") {
+		t.Errorf("snippet with markup = %+v", results)
+	}
+
+	expectMessage(t, server, "GET", "/search", "", http.StatusBadRequest)
+	expectMessage(t, server, "GET", "/search?q=x&type=user", "", http.StatusBadRequest)
+	expectMessage(t, server, "GET", "/search?q=x&offset=-1", "", http.StatusBadRequest)
+	expectMessage(t, server, "GET", "/search?q=x&since=yesterday", "", http.StatusBadRequest)
+	expectMessage(t, server, "GET", "/search?q=x&thread=missing", "", http.StatusNotFound)
+}
+
+func TestServiceRoutes(t *testing.T) {
+	server, repos := testServerRepos(t)
+	defer server.Close()
+
+	createUser(t, server, "u1")
+	createUser(t, server, "u2")
+	if err := repos.roles.SetAdmin(context.Background(), "u2", true); err != nil {
+		t.Fatal(err)
+	}
+	createForum(t, server, "s", "u1")
+	createThread(t, server, "s", "u2", "")
+	createPosts(t, server, "1", models.Posts{{Author: "u1", Message: "a"}, {Author: "u2", Message: "b"}})
+
+	status := &models.Status{}
+	expect(t, server, "GET", "/service/status", "", http.StatusOK, status)
+	if *status != (models.Status{User: 2, Forum: 1, Thread: 1, Post: 2}) {
+		t.Errorf("status = %+v", status)
+	}
+
+	expectMessage(t, server, "POST", "/service/clear", "", http.StatusUnauthorized)
+	expectMessageAs(t, server, tokenFor("u1"), "POST", "/service/clear", "", http.StatusForbidden)
+	expectAs(t, server, tokenFor("u2"), "POST", "/service/clear", "", http.StatusOK, nil)
+	expect(t, server, "GET", "/service/status", "", http.StatusOK, status)
+	if *status != (models.Status{}) {
+		t.Errorf("status after clear = %+v", status)
+	}
+	expectMessage(t, server, "GET", "/user/u1/profile", "", http.StatusNotFound)
+}
+
+func TestHealthRoutes(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	expect(t, server, "GET", "/healthz", "", http.StatusOK, nil)
+	expect(t, server, "GET", "/readyz", "", http.StatusOK, nil)
+
+	metrics := expect(t, server, "GET", "/metrics", "", http.StatusOK, nil)
+	if !bytes.Contains(metrics, []byte("db_forum_http_requests_total")) {
+		t.Errorf("metrics do not contain request counter")
+	}
+}
+
+// forumTime время создания i-го треда, с шагом в час
+func forumTime(i int) time.Time {
+	return time.Date(2020, 1, 1, i, 0, 0, 0, time.UTC)
+}
+
+func threadSlugs(threads models.Threads) []string {
+	slugs := make([]string, 0, len(threads))
+	for _, thread := range threads {
+		slugs = append(slugs, thread.Slug)
+	}
+	return slugs
+}
+
+func nicknames(users models.Users) string {
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Nickname)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AntonPriyma/db_forum/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "db_forum"
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by mux route template, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by mux route template and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Repository method latency, variant distinguishes query flavours of one method.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"repository", "method", "variant"})
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, requestDuration, queryDuration)
+}
+
+// Handler отдаёт метрики в формате prometheus
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware считает запросы и их длительность по шаблону роута,
+// например /thread/{slug_or_id}/create, чтобы не плодить метки на каждый id.
+// Подключается через mux.Router.Use, иначе роут ещё не известен.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := utils.NewResponseRecorder(w)
+
+		next.ServeHTTP(recorder, r)
+
+		route := utils.RouteTemplate(r)
+		requestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(recorder.Status)).Inc()
+		requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveQuery используется в репозиториях как
+// defer metrics.ObserveQuery("posts", "Create", "", time.Now())
+func ObserveQuery(repository, method, variant string, start time.Time) {
+	queryDuration.WithLabelValues(repository, method, variant).Observe(time.Since(start).Seconds())
+}
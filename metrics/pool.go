@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolMaxDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "max_connections"),
+		"Configured pool size.", nil, nil)
+	poolOpenDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "open_connections"),
+		"Connections currently opened by the pool.", nil, nil)
+	poolAcquiredDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "acquired_connections"),
+		"Connections currently checked out of the pool.", nil, nil)
+	poolAvailableDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "available_connections"),
+		"Idle connections ready to be acquired.", nil, nil)
+)
+
+// poolCollector снимает статистику pgx.ConnPool в момент scrape.
+// Время ожидания соединения pgx v3 не отдаёт, исчерпание пула видно по acquired == max,
+// а само ожидание входит в db_forum_db_query_duration_seconds.
+type poolCollector struct {
+	pool *pgx.ConnPool
+}
+
+// RegisterPool регистрирует метрики пула соединений
+func RegisterPool(pool *pgx.ConnPool) error {
+	return prometheus.Register(&poolCollector{pool: pool})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolMaxDesc
+	ch <- poolOpenDesc
+	ch <- poolAcquiredDesc
+	ch <- poolAvailableDesc
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolMaxDesc, prometheus.GaugeValue, float64(stat.MaxConnections))
+	ch <- prometheus.MustNewConstMetric(poolOpenDesc, prometheus.GaugeValue, float64(stat.CurrentConnections))
+	ch <- prometheus.MustNewConstMetric(poolAcquiredDesc, prometheus.GaugeValue, float64(stat.CurrentConnections-stat.AvailableConnections))
+	ch <- prometheus.MustNewConstMetric(poolAvailableDesc, prometheus.GaugeValue, float64(stat.AvailableConnections))
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Credentials тело POST /auth/login
+//easyjson:json
+type Credentials struct {
+	Nickname string `json:"nickname"`
+	Password string `json:"password"`
+}
+
+// Session выданный токен, передаётся в заголовке Authorization: Bearer <token>
+//easyjson:json
+type Session struct {
+	Nickname string    `json:"nickname"`
+	Token    string    `json:"token"`
+	Expires  time.Time `json:"expires"`
+}
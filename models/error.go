@@ -36,13 +36,20 @@ const (
 	PgxErrNotNull    = "23502"
 	PgxErrForeignKey = "23503"
 	PgxErrUnique     = "23505"
+	PgxErrCheck      = "23514"
 	NoRowsInResult   = "no rows in result set"
+
+	// PgxErrForumArchived свой код триггера forum_archived
+	PgxErrForumArchived = "DF001"
 )
 
 // Ошибки запросов
 var (
 	ForumIsExist			 = errors.New("Forum was created earlier")
 	ForumNotFound			 = errors.New("Forum not found")
+	ForumParentNotFound		 = errors.New("Parent forum not found")
+	ForumHasChildren		 = errors.New("Forum has sub-forums")
+	ForumArchived			 = errors.New("Forum is archived")
 	ForumOrAuthorNotFound	 = errors.New("Forum or Author not found")
 	UserNotFound			 = errors.New("User not found")
 	UserIsExist				 = errors.New("User was created earlier")
@@ -51,4 +58,8 @@ var (
 	ThreadNotFound			 = errors.New("Thread not found")
 	PostParentNotFound		 = errors.New("No parent for thread")
 	PostNotFound			 = errors.New("Post not found")
+	InvalidCredentials		 = errors.New("Invalid nickname or password")
+	ModeratorNotFound		 = errors.New("Moderator not found")
+	VoteNotFound			 = errors.New("Vote not found")
+	InvalidVoice			 = errors.New("Voice must be -1 or 1")
 )
@@ -14,6 +14,61 @@ type Forum struct {
 	Posts   int64  `json:"posts"`
 	Threads int32  `json:"threads"`
 	Owner   string `json:"user"`
+	// Archived форум и его подфорумы только для чтения, треды и посты остаются видны
+	Archived bool `json:"archived,omitempty"`
+	// Parent slug родительского форума, счётчики posts и threads включают все подфорумы
+	Parent string `json:"parent,omitempty"`
+	// Breadcrumbs предки от корня до родителя, заполняются только в /forum/{slug}/details
+	Breadcrumbs []ForumCrumb `json:"breadcrumbs,omitempty"`
+}
+
+// ForumCrumb звено цепочки предков форума
+type ForumCrumb struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
+//easyjson:json
+type Forums []*Forum
+
+// Сортировки GET /forums, при равенстве порядок по slug. title и slug сравниваются без учёта регистра
+const (
+	ForumSortSlug    = "slug"
+	ForumSortTitle   = "title"
+	ForumSortThreads = "threads"
+	ForumSortPosts   = "posts"
+)
+
+// IsForumSort проверка параметра sort списка форумов
+func IsForumSort(sort string) bool {
+	switch sort {
+	case ForumSortSlug, ForumSortTitle, ForumSortThreads, ForumSortPosts:
+		return true
+	}
+	return false
+}
+
+// ForumUpdate изменение форума, пустые поля не трогаются.
+// Новый Slug переезжает на все треды, посты и forum_users.
+//easyjson:json
+type ForumUpdate struct {
+	Slug  string `json:"slug,omitempty"`
+	Title string `json:"title,omitempty"`
+	Owner string `json:"user,omitempty"`
+}
+
+// IsEmpty в запросе нечего менять
+func (f *ForumUpdate) IsEmpty() bool {
+	return f.Slug == "" && f.Title == "" && f.Owner == ""
+}
+
+// ForumDeleted итог DELETE /forum/{slug}: сколько тредов и постов удалено или, при архивации, закрыто для записи
+//easyjson:json
+type ForumDeleted struct {
+	Forum    string `json:"forum"`
+	Archived bool   `json:"archived"`
+	Threads  int64  `json:"threads"`
+	Posts    int64  `json:"posts"`
 }
 
 var (
@@ -15,10 +15,68 @@ type Post struct {
 	Message string `json:"message"`
 	Parent int64 `json:"parent,omitempty"`
 	Thread int32 `json:"thread,omitempty"`
+	Deleted bool `json:"deleted,omitempty"`
+	DeletedBy string `json:"deletedBy,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// Votes сумма голосов за пост, пересчитывается триггерами post_votes
+	Votes int32 `json:"votes"`
+	// Voice текущий голос проголосовавшего, только в ответах на голосование
+	Voice *int32 `json:"voice,omitempty"`
+}
+
+// DeletedMessage текст заглушки на месте удалённого или скрытого поста и треда
+const DeletedMessage = "[deleted]"
+
+// HideDeleted подменяет текст скрытого поста заглушкой, место в дереве при этом сохраняется
+func (p *Post) HideDeleted() {
+	if p.Deleted {
+		p.Message = DeletedMessage
+	}
+}
+
+//easyjson:json
+type Hide struct {
+	Nickname string `json:"nickname"`
 }
 
 type PostUpdate struct {
 	Message string `json:"message,omitempty"`
+	// Editor кто правит пост, по умолчанию автор
+	Editor string `json:"editor,omitempty"`
+}
+
+// PostRevision версия текста поста. Ревизия 1 - исходный текст, следующие - правки
+//easyjson:json
+type PostRevision struct {
+	Revision int32     `json:"revision"`
+	Message  string    `json:"message"`
+	Editor   string    `json:"editor"`
+	Created  time.Time `json:"created"`
+}
+
+//easyjson:json
+type PostRevisions []*PostRevision
+
+// Операции в PostDiff
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffChunk кусок текста, одинаковый в обеих ревизиях, добавленный или удалённый
+type DiffChunk struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// PostDiff разница между ревизиями From и To
+//easyjson:json
+type PostDiff struct {
+	Post    int64       `json:"post"`
+	From    int32       `json:"from"`
+	To      int32       `json:"to"`
+	Changes []DiffChunk `json:"changes"`
 }
 
 //easyjson:json
@@ -7,6 +7,7 @@ import (
 	easyjson "github.com/mailru/easyjson"
 	jlexer "github.com/mailru/easyjson/jlexer"
 	jwriter "github.com/mailru/easyjson/jwriter"
+	time "time"
 )
 
 // suppress unused package warning
@@ -17,85 +18,86 @@ var (
 	_ easyjson.Marshaler
 )
 
-func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels(in *jlexer.Lexer, out *Posts) {
+func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels(in *jlexer.Lexer, out *PostUpdate) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
-		in.Skip()
-		*out = nil
-	} else {
-		in.Delim('[')
-		if *out == nil {
-			if !in.IsDelim(']') {
-				*out = make(Posts, 0, 8)
-			} else {
-				*out = Posts{}
-			}
-		} else {
-			*out = (*out)[:0]
+		if isTopLevel {
+			in.Consumed()
 		}
-		for !in.IsDelim(']') {
-			var v1 *Post
-			if in.IsNull() {
-				in.Skip()
-				v1 = nil
-			} else {
-				if v1 == nil {
-					v1 = new(Post)
-				}
-				(*v1).UnmarshalEasyJSON(in)
-			}
-			*out = append(*out, v1)
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeString()
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
 			in.WantComma()
+			continue
 		}
-		in.Delim(']')
+		switch key {
+		case "message":
+			out.Message = string(in.String())
+		case "editor":
+			out.Editor = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
 	}
+	in.Delim('}')
 	if isTopLevel {
 		in.Consumed()
 	}
 }
-func easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels(out *jwriter.Writer, in Posts) {
-	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
-		out.RawString("null")
-	} else {
-		out.RawByte('[')
-		for v2, v3 := range in {
-			if v2 > 0 {
-				out.RawByte(',')
-			}
-			if v3 == nil {
-				out.RawString("null")
-			} else {
-				(*v3).MarshalEasyJSON(out)
-			}
+func easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels(out *jwriter.Writer, in PostUpdate) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if in.Message != "" {
+		const prefix string = ",\"message\":"
+		first = false
+		out.RawString(prefix[1:])
+		out.String(string(in.Message))
+	}
+	if in.Editor != "" {
+		const prefix string = ",\"editor\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
 		}
-		out.RawByte(']')
+		out.String(string(in.Editor))
 	}
+	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v Posts) MarshalJSON() ([]byte, error) {
+func (v PostUpdate) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
 	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
-func (v Posts) MarshalEasyJSON(w *jwriter.Writer) {
+func (v PostUpdate) MarshalEasyJSON(w *jwriter.Writer) {
 	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
-func (v *Posts) UnmarshalJSON(data []byte) error {
+func (v *PostUpdate) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
 	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
-func (v *Posts) UnmarshalEasyJSON(l *jlexer.Lexer) {
+func (v *PostUpdate) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels(l, v)
 }
-func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels1(in *jlexer.Lexer, out *PostUpdate) {
+func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels1(in *jlexer.Lexer, out *PostRevision) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -114,8 +116,16 @@ func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels1(in *jlexer.Lexer,
 			continue
 		}
 		switch key {
+		case "revision":
+			out.Revision = int32(in.Int32())
 		case "message":
 			out.Message = string(in.String())
+		case "editor":
+			out.Editor = string(in.String())
+		case "created":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Created).UnmarshalJSON(data))
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -126,40 +136,54 @@ func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels1(in *jlexer.Lexer,
 		in.Consumed()
 	}
 }
-func easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels1(out *jwriter.Writer, in PostUpdate) {
+func easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels1(out *jwriter.Writer, in PostRevision) {
 	out.RawByte('{')
 	first := true
 	_ = first
-	if in.Message != "" {
-		const prefix string = ",\"message\":"
-		first = false
+	{
+		const prefix string = ",\"revision\":"
 		out.RawString(prefix[1:])
+		out.Int32(int32(in.Revision))
+	}
+	{
+		const prefix string = ",\"message\":"
+		out.RawString(prefix)
 		out.String(string(in.Message))
 	}
+	{
+		const prefix string = ",\"editor\":"
+		out.RawString(prefix)
+		out.String(string(in.Editor))
+	}
+	{
+		const prefix string = ",\"created\":"
+		out.RawString(prefix)
+		out.Raw((in.Created).MarshalJSON())
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v PostUpdate) MarshalJSON() ([]byte, error) {
+func (v PostRevision) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
 	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels1(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
-func (v PostUpdate) MarshalEasyJSON(w *jwriter.Writer) {
+func (v PostRevision) MarshalEasyJSON(w *jwriter.Writer) {
 	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels1(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
-func (v *PostUpdate) UnmarshalJSON(data []byte) error {
+func (v *PostRevision) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
 	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels1(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
-func (v *PostUpdate) UnmarshalEasyJSON(l *jlexer.Lexer) {
+func (v *PostRevision) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels1(l, v)
 }
 func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels2(in *jlexer.Lexer, out *PostFull) {
@@ -297,7 +321,126 @@ func (v *PostFull) UnmarshalJSON(data []byte) error {
 func (v *PostFull) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels2(l, v)
 }
-func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels3(in *jlexer.Lexer, out *Post) {
+func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels3(in *jlexer.Lexer, out *PostDiff) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeString()
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "post":
+			out.Post = int64(in.Int64())
+		case "from":
+			out.From = int32(in.Int32())
+		case "to":
+			out.To = int32(in.Int32())
+		case "changes":
+			if in.IsNull() {
+				in.Skip()
+				out.Changes = nil
+			} else {
+				in.Delim('[')
+				if out.Changes == nil {
+					if !in.IsDelim(']') {
+						out.Changes = make([]DiffChunk, 0, 2)
+					} else {
+						out.Changes = []DiffChunk{}
+					}
+				} else {
+					out.Changes = (out.Changes)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v1 DiffChunk
+					(v1).UnmarshalEasyJSON(in)
+					out.Changes = append(out.Changes, v1)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels3(out *jwriter.Writer, in PostDiff) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"post\":"
+		out.RawString(prefix[1:])
+		out.Int64(int64(in.Post))
+	}
+	{
+		const prefix string = ",\"from\":"
+		out.RawString(prefix)
+		out.Int32(int32(in.From))
+	}
+	{
+		const prefix string = ",\"to\":"
+		out.RawString(prefix)
+		out.Int32(int32(in.To))
+	}
+	{
+		const prefix string = ",\"changes\":"
+		out.RawString(prefix)
+		if in.Changes == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v2, v3 := range in.Changes {
+				if v2 > 0 {
+					out.RawByte(',')
+				}
+				(v3).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v PostDiff) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v PostDiff) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *PostDiff) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *PostDiff) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels3(l, v)
+}
+func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels4(in *jlexer.Lexer, out *Post) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -334,6 +477,34 @@ func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels3(in *jlexer.Lexer,
 			out.Parent = int64(in.Int64())
 		case "thread":
 			out.Thread = int32(in.Int32())
+		case "deleted":
+			out.Deleted = bool(in.Bool())
+		case "deletedBy":
+			out.DeletedBy = string(in.String())
+		case "deletedAt":
+			if in.IsNull() {
+				in.Skip()
+				out.DeletedAt = nil
+			} else {
+				if out.DeletedAt == nil {
+					out.DeletedAt = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.DeletedAt).UnmarshalJSON(data))
+				}
+			}
+		case "votes":
+			out.Votes = int32(in.Int32())
+		case "voice":
+			if in.IsNull() {
+				in.Skip()
+				out.Voice = nil
+			} else {
+				if out.Voice == nil {
+					out.Voice = new(int32)
+				}
+				*out.Voice = int32(in.Int32())
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -344,7 +515,7 @@ func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels3(in *jlexer.Lexer,
 		in.Consumed()
 	}
 }
-func easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels3(out *jwriter.Writer, in Post) {
+func easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels4(out *jwriter.Writer, in Post) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -388,29 +559,271 @@ func easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels3(out *jwriter.Write
 		out.RawString(prefix)
 		out.Int32(int32(in.Thread))
 	}
+	if in.Deleted {
+		const prefix string = ",\"deleted\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Deleted))
+	}
+	if in.DeletedBy != "" {
+		const prefix string = ",\"deletedBy\":"
+		out.RawString(prefix)
+		out.String(string(in.DeletedBy))
+	}
+	if in.DeletedAt != nil {
+		const prefix string = ",\"deletedAt\":"
+		out.RawString(prefix)
+		out.Raw((*in.DeletedAt).MarshalJSON())
+	}
+	{
+		const prefix string = ",\"votes\":"
+		out.RawString(prefix)
+		out.Int32(int32(in.Votes))
+	}
+	if in.Voice != nil {
+		const prefix string = ",\"voice\":"
+		out.RawString(prefix)
+		out.Int32(int32(*in.Voice))
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
 func (v Post) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels3(&w, v)
+	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels4(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v Post) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels3(w, v)
+	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels4(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *Post) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels3(&r, v)
+	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels4(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *Post) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels3(l, v)
+	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels4(l, v)
+}
+func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels5(in *jlexer.Lexer, out *Hide) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeString()
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "nickname":
+			out.Nickname = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels5(out *jwriter.Writer, in Hide) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"nickname\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Nickname))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Hide) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels5(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Hide) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels5(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Hide) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels5(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Hide) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels5(l, v)
+}
+func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels6(in *jlexer.Lexer, out *DiffChunk) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeString()
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "op":
+			out.Op = string(in.String())
+		case "text":
+			out.Text = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels6(out *jwriter.Writer, in DiffChunk) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"op\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Op))
+	}
+	{
+		const prefix string = ",\"text\":"
+		out.RawString(prefix)
+		out.String(string(in.Text))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v DiffChunk) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels6(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v DiffChunk) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels6(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *DiffChunk) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels6(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *DiffChunk) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels6(l, v)
+}
+func easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels7(in *jlexer.Lexer, out *Posts) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(Posts, 0, 8)
+			} else {
+				*out = Posts{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v1 *Post
+			if in.IsNull() {
+				in.Skip()
+				v1 = nil
+			} else {
+				if v1 == nil {
+					v1 = new(Post)
+				}
+				(*v1).UnmarshalEasyJSON(in)
+			}
+			*out = append(*out, v1)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels7(out *jwriter.Writer, in Posts) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v2, v3 := range in {
+			if v2 > 0 {
+				out.RawByte(',')
+			}
+			if v3 == nil {
+				out.RawString("null")
+			} else {
+				(*v3).MarshalEasyJSON(out)
+			}
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Posts) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels7(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Posts) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson5a72dc82EncodeGithubComAntonPriymaDbForumModels7(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Posts) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels7(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Posts) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson5a72dc82DecodeGithubComAntonPriymaDbForumModels7(l, v)
 }
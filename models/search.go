@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Типы результатов поиска
+const (
+	SearchPost   = "post"
+	SearchThread = "thread"
+)
+
+// SearchQuery параметры GET /search, пустые фильтры не применяются
+type SearchQuery struct {
+	Query  string
+	Type   string
+	Forum  string
+	Thread int32
+	Author string
+	Since  *time.Time
+	Until  *time.Time
+	Limit  int
+	Offset int
+}
+
+// SearchResult найденный пост или тред, Snippet - фрагмент экранированного текста с совпадениями в <b></b>
+//easyjson:json
+type SearchResult struct {
+	Type    string    `json:"type"`
+	ID      int64     `json:"id"`
+	Thread  int32     `json:"thread"`
+	Forum   string    `json:"forum"`
+	Author  string    `json:"author"`
+	Created time.Time `json:"created"`
+	Rank    float32   `json:"rank"`
+	Snippet string    `json:"snippet"`
+}
+
+//easyjson:json
+type SearchResults []*SearchResult
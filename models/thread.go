@@ -13,12 +13,32 @@ type Thread struct {
 	Message string `json:"message"`
 	Slug string `json:"slug,omitempty"`
 	Title string `json:"title"`
-	Votes int32 `json:"votes,omitempty"`
+	Votes int32 `json:"votes"`
+	Deleted bool `json:"deleted,omitempty"`
+	DeletedBy string `json:"deletedBy,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// Voice текущий голос проголосовавшего, только в ответах на голосование
+	Voice *int32 `json:"voice,omitempty"`
 }
 
+// HideDeleted подменяет заголовок и текст скрытого треда заглушкой
+func (t *Thread) HideDeleted() {
+	if t.Deleted {
+		t.Title = DeletedMessage
+		t.Message = DeletedMessage
+	}
+}
+
+// ThreadUpdate частичное изменение треда, пустые поля не трогаются
 type ThreadUpdate struct {
 	Message string `json:"message,omitempty"`
 	Title string `json:"title,omitempty"`
+	Slug string `json:"slug,omitempty"`
+}
+
+// IsEmpty в запросе нечего менять
+func (t *ThreadUpdate) IsEmpty() bool {
+	return t.Message == "" && t.Title == "" && t.Slug == ""
 }
 
 //easyjson:json
@@ -31,6 +51,11 @@ type Vote struct {
 	VoiceImpl bool
 }
 
+// IsValid голос может быть только -1 или +1
+func (v *Vote) IsValid() bool {
+	return v.Voice == -1 || v.Voice == 1
+}
+
 
 
 
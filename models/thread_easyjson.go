@@ -7,6 +7,7 @@ import (
 	easyjson "github.com/mailru/easyjson"
 	jlexer "github.com/mailru/easyjson/jlexer"
 	jwriter "github.com/mailru/easyjson/jwriter"
+	time "time"
 )
 
 // suppress unused package warning
@@ -97,85 +98,7 @@ func (v *Vote) UnmarshalJSON(data []byte) error {
 func (v *Vote) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson2d00218DecodeGithubComAntonPriymaDbForumModels(l, v)
 }
-func easyjson2d00218DecodeGithubComAntonPriymaDbForumModels1(in *jlexer.Lexer, out *Threads) {
-	isTopLevel := in.IsStart()
-	if in.IsNull() {
-		in.Skip()
-		*out = nil
-	} else {
-		in.Delim('[')
-		if *out == nil {
-			if !in.IsDelim(']') {
-				*out = make(Threads, 0, 8)
-			} else {
-				*out = Threads{}
-			}
-		} else {
-			*out = (*out)[:0]
-		}
-		for !in.IsDelim(']') {
-			var v1 *Thread
-			if in.IsNull() {
-				in.Skip()
-				v1 = nil
-			} else {
-				if v1 == nil {
-					v1 = new(Thread)
-				}
-				(*v1).UnmarshalEasyJSON(in)
-			}
-			*out = append(*out, v1)
-			in.WantComma()
-		}
-		in.Delim(']')
-	}
-	if isTopLevel {
-		in.Consumed()
-	}
-}
-func easyjson2d00218EncodeGithubComAntonPriymaDbForumModels1(out *jwriter.Writer, in Threads) {
-	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
-		out.RawString("null")
-	} else {
-		out.RawByte('[')
-		for v2, v3 := range in {
-			if v2 > 0 {
-				out.RawByte(',')
-			}
-			if v3 == nil {
-				out.RawString("null")
-			} else {
-				(*v3).MarshalEasyJSON(out)
-			}
-		}
-		out.RawByte(']')
-	}
-}
-
-// MarshalJSON supports json.Marshaler interface
-func (v Threads) MarshalJSON() ([]byte, error) {
-	w := jwriter.Writer{}
-	easyjson2d00218EncodeGithubComAntonPriymaDbForumModels1(&w, v)
-	return w.Buffer.BuildBytes(), w.Error
-}
-
-// MarshalEasyJSON supports easyjson.Marshaler interface
-func (v Threads) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2d00218EncodeGithubComAntonPriymaDbForumModels1(w, v)
-}
-
-// UnmarshalJSON supports json.Unmarshaler interface
-func (v *Threads) UnmarshalJSON(data []byte) error {
-	r := jlexer.Lexer{Data: data}
-	easyjson2d00218DecodeGithubComAntonPriymaDbForumModels1(&r, v)
-	return r.Error()
-}
-
-// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
-func (v *Threads) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson2d00218DecodeGithubComAntonPriymaDbForumModels1(l, v)
-}
-func easyjson2d00218DecodeGithubComAntonPriymaDbForumModels2(in *jlexer.Lexer, out *ThreadUpdate) {
+func easyjson2d00218DecodeGithubComAntonPriymaDbForumModels1(in *jlexer.Lexer, out *ThreadUpdate) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -198,6 +121,8 @@ func easyjson2d00218DecodeGithubComAntonPriymaDbForumModels2(in *jlexer.Lexer, o
 			out.Message = string(in.String())
 		case "title":
 			out.Title = string(in.String())
+		case "slug":
+			out.Slug = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -208,7 +133,7 @@ func easyjson2d00218DecodeGithubComAntonPriymaDbForumModels2(in *jlexer.Lexer, o
 		in.Consumed()
 	}
 }
-func easyjson2d00218EncodeGithubComAntonPriymaDbForumModels2(out *jwriter.Writer, in ThreadUpdate) {
+func easyjson2d00218EncodeGithubComAntonPriymaDbForumModels1(out *jwriter.Writer, in ThreadUpdate) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -228,33 +153,43 @@ func easyjson2d00218EncodeGithubComAntonPriymaDbForumModels2(out *jwriter.Writer
 		}
 		out.String(string(in.Title))
 	}
+	if in.Slug != "" {
+		const prefix string = ",\"slug\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.Slug))
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
 func (v ThreadUpdate) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson2d00218EncodeGithubComAntonPriymaDbForumModels2(&w, v)
+	easyjson2d00218EncodeGithubComAntonPriymaDbForumModels1(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v ThreadUpdate) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2d00218EncodeGithubComAntonPriymaDbForumModels2(w, v)
+	easyjson2d00218EncodeGithubComAntonPriymaDbForumModels1(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *ThreadUpdate) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson2d00218DecodeGithubComAntonPriymaDbForumModels2(&r, v)
+	easyjson2d00218DecodeGithubComAntonPriymaDbForumModels1(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *ThreadUpdate) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson2d00218DecodeGithubComAntonPriymaDbForumModels2(l, v)
+	easyjson2d00218DecodeGithubComAntonPriymaDbForumModels1(l, v)
 }
-func easyjson2d00218DecodeGithubComAntonPriymaDbForumModels3(in *jlexer.Lexer, out *Thread) {
+func easyjson2d00218DecodeGithubComAntonPriymaDbForumModels2(in *jlexer.Lexer, out *Thread) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -291,6 +226,32 @@ func easyjson2d00218DecodeGithubComAntonPriymaDbForumModels3(in *jlexer.Lexer, o
 			out.Title = string(in.String())
 		case "votes":
 			out.Votes = int32(in.Int32())
+		case "deleted":
+			out.Deleted = bool(in.Bool())
+		case "deletedBy":
+			out.DeletedBy = string(in.String())
+		case "deletedAt":
+			if in.IsNull() {
+				in.Skip()
+				out.DeletedAt = nil
+			} else {
+				if out.DeletedAt == nil {
+					out.DeletedAt = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.DeletedAt).UnmarshalJSON(data))
+				}
+			}
+		case "voice":
+			if in.IsNull() {
+				in.Skip()
+				out.Voice = nil
+			} else {
+				if out.Voice == nil {
+					out.Voice = new(int32)
+				}
+				*out.Voice = int32(in.Int32())
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -301,7 +262,7 @@ func easyjson2d00218DecodeGithubComAntonPriymaDbForumModels3(in *jlexer.Lexer, o
 		in.Consumed()
 	}
 }
-func easyjson2d00218EncodeGithubComAntonPriymaDbForumModels3(out *jwriter.Writer, in Thread) {
+func easyjson2d00218EncodeGithubComAntonPriymaDbForumModels2(out *jwriter.Writer, in Thread) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -340,34 +301,132 @@ func easyjson2d00218EncodeGithubComAntonPriymaDbForumModels3(out *jwriter.Writer
 		out.RawString(prefix)
 		out.String(string(in.Title))
 	}
-	if in.Votes != 0 {
+	{
 		const prefix string = ",\"votes\":"
 		out.RawString(prefix)
 		out.Int32(int32(in.Votes))
 	}
+	if in.Deleted {
+		const prefix string = ",\"deleted\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Deleted))
+	}
+	if in.DeletedBy != "" {
+		const prefix string = ",\"deletedBy\":"
+		out.RawString(prefix)
+		out.String(string(in.DeletedBy))
+	}
+	if in.DeletedAt != nil {
+		const prefix string = ",\"deletedAt\":"
+		out.RawString(prefix)
+		out.Raw((*in.DeletedAt).MarshalJSON())
+	}
+	if in.Voice != nil {
+		const prefix string = ",\"voice\":"
+		out.RawString(prefix)
+		out.Int32(int32(*in.Voice))
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
 func (v Thread) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson2d00218EncodeGithubComAntonPriymaDbForumModels3(&w, v)
+	easyjson2d00218EncodeGithubComAntonPriymaDbForumModels2(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v Thread) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2d00218EncodeGithubComAntonPriymaDbForumModels3(w, v)
+	easyjson2d00218EncodeGithubComAntonPriymaDbForumModels2(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *Thread) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson2d00218DecodeGithubComAntonPriymaDbForumModels3(&r, v)
+	easyjson2d00218DecodeGithubComAntonPriymaDbForumModels2(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *Thread) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson2d00218DecodeGithubComAntonPriymaDbForumModels2(l, v)
+}
+func easyjson2d00218DecodeGithubComAntonPriymaDbForumModels3(in *jlexer.Lexer, out *Threads) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(Threads, 0, 8)
+			} else {
+				*out = Threads{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v1 *Thread
+			if in.IsNull() {
+				in.Skip()
+				v1 = nil
+			} else {
+				if v1 == nil {
+					v1 = new(Thread)
+				}
+				(*v1).UnmarshalEasyJSON(in)
+			}
+			*out = append(*out, v1)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson2d00218EncodeGithubComAntonPriymaDbForumModels3(out *jwriter.Writer, in Threads) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v2, v3 := range in {
+			if v2 > 0 {
+				out.RawByte(',')
+			}
+			if v3 == nil {
+				out.RawString("null")
+			} else {
+				(*v3).MarshalEasyJSON(out)
+			}
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Threads) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson2d00218EncodeGithubComAntonPriymaDbForumModels3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Threads) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson2d00218EncodeGithubComAntonPriymaDbForumModels3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Threads) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson2d00218DecodeGithubComAntonPriymaDbForumModels3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Threads) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson2d00218DecodeGithubComAntonPriymaDbForumModels3(l, v)
 }
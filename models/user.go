@@ -12,6 +12,10 @@ type User struct {
 	Email string `json:"email"`
 	Fullname string `json:"fullname"`
 	Nickname string `json:"nickname,omitempty"`
+	// Password только во входящем запросе на создание, в ответах не отдаётся
+	Password string `json:"password,omitempty"`
+	// Karma сумма голосов за треды и посты пользователя, из запросов не принимается
+	Karma int32 `json:"karma"`
 }
 
 
@@ -122,6 +122,10 @@ func easyjson9e1087fdDecodeGithubComAntonPriymaDbForumModels1(in *jlexer.Lexer,
 			out.Fullname = string(in.String())
 		case "nickname":
 			out.Nickname = string(in.String())
+		case "password":
+			out.Password = string(in.String())
+		case "karma":
+			out.Karma = int32(in.Int32())
 		default:
 			in.SkipRecursive()
 		}
@@ -162,6 +166,16 @@ func easyjson9e1087fdEncodeGithubComAntonPriymaDbForumModels1(out *jwriter.Write
 		out.RawString(prefix)
 		out.String(string(in.Nickname))
 	}
+	if in.Password != "" {
+		const prefix string = ",\"password\":"
+		out.RawString(prefix)
+		out.String(string(in.Password))
+	}
+	{
+		const prefix string = ",\"karma\":"
+		out.RawString(prefix)
+		out.Int32(int32(in.Karma))
+	}
 	out.RawByte('}')
 }
 
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"github.com/AntonPriyma/db_forum/config"
 	"github.com/AntonPriyma/db_forum/models"
 	"github.com/jackc/pgx"
 )
@@ -21,24 +22,24 @@ type exequeryer interface {
 
 var db *pgx.ConnPool
 
-func ConnetctDB(service *DBService, dbUser, dbPass, dbHost, dbName string) *models.Error {
+func ConnetctDB(service *DBService, cfg config.Database) *models.Error {
 	runtimeParams := make(map[string] string)
 	runtimeParams["application_name"] = "dz"
 	newDB, err := pgx.NewConnPool(pgx.ConnPoolConfig{
 		ConnConfig: pgx.ConnConfig{
-			Host:     dbHost,
-			User:     dbUser,
-			Password: dbPass,
-			Port:     5432,
+			Host:     cfg.Host,
+			User:     cfg.User,
+			Password: cfg.Password,
+			Port:     cfg.Port,
 			TLSConfig: 		nil,
 			UseFallbackTLS: false,
-			Database: dbName,
+			Database: cfg.Name,
 			RuntimeParams: 	runtimeParams,
 		},
-		MaxConnections: 20,
+		MaxConnections: cfg.MaxConnections,
 
 		AfterConnect:   nil,
-		AcquireTimeout: 0,
+		AcquireTimeout: cfg.AcquireTimeout,
 	})
 	if err != nil {
 		return models.NewError(models.InternalDatabase, err.Error())
@@ -56,3 +57,10 @@ func ConnetctDB(service *DBService, dbUser, dbPass, dbHost, dbName string) *mode
 func GetDB() *pgx.ConnPool {
 	return db
 }
+
+// CloseDB закрывает пул, выданные соединения закрываются при возврате в пул
+func CloseDB() {
+	if db != nil {
+		db.Close()
+	}
+}
@@ -1,10 +1,23 @@
 package repository
 
 import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AntonPriyma/db_forum/metrics"
 	"github.com/AntonPriyma/db_forum/models"
+	"github.com/AntonPriyma/db_forum/utils"
 	"github.com/jackc/pgx"
 )
 
+// ServiceRepository служебные операции над хранилищем: статистика, очистка и проверка готовности
+type ServiceRepository interface {
+	GetStatus(ctx context.Context) (*models.Status, *models.Error)
+	Load(ctx context.Context) *models.Error
+	Ready(ctx context.Context) error
+}
+
 type DBService struct {
 	DB *pgx.ConnPool
 }
@@ -12,8 +25,9 @@ type DBService struct {
 func NewDBService() *DBService {
 	return &DBService{}
 }
-func(s *DBService) GetStatus() (*models.Status, *models.Error) {
-	tx, err := s.DB.Begin()
+func(s *DBService) GetStatus(ctx context.Context) (*models.Status, *models.Error) {
+	defer metrics.ObserveQuery("service", "GetStatus", "", time.Now())
+	tx, err := s.DB.BeginEx(ctx, nil)
 	if err != nil {
 		return nil, models.NewError(models.InternalDatabase, "can not open status tx")
 	}
@@ -21,19 +35,19 @@ func(s *DBService) GetStatus() (*models.Status, *models.Error) {
 
 	// можно сделать на рефлексии, но зачем так тормозить
 	status := &models.Status{}
-	row := tx.QueryRow(`SELECT count(*) FROM forums`)
+	row := tx.QueryRowEx(ctx, `SELECT count(*) FROM forums`, nil)
 	if err = row.Scan(&status.Forum); err != nil {
 		return nil, models.NewError(models.InternalDatabase, err.Error())
 	}
-	row = tx.QueryRow(`SELECT count(*) FROM posts`)
+	row = tx.QueryRowEx(ctx, `SELECT count(*) FROM posts`, nil)
 	if err = row.Scan(&status.Post); err != nil {
 		return nil, models.NewError(models.InternalDatabase, err.Error())
 	}
-	row = tx.QueryRow(`SELECT count(*) FROM threads`)
+	row = tx.QueryRowEx(ctx, `SELECT count(*) FROM threads`, nil)
 	if err = row.Scan(&status.Thread); err != nil {
 		return nil, models.NewError(models.InternalDatabase, err.Error())
 	}
-	row = tx.QueryRow(`SELECT count(*) FROM users`)
+	row = tx.QueryRowEx(ctx, `SELECT count(*) FROM users`, nil)
 	if err = row.Scan(&status.User); err != nil {
 		return nil, models.NewError(models.InternalDatabase, err.Error())
 	}
@@ -46,17 +60,65 @@ func(s *DBService) GetStatus() (*models.Status, *models.Error) {
 	return status, nil
 }
 
-func(s *DBService) Load() *models.Error {
-	_, err := s.DB.Exec(`
-TRUNCATE users, forums, threads, posts, votes, forum_users;
-`)
+func(s *DBService) Load(ctx context.Context) *models.Error {
+	defer metrics.ObserveQuery("service", "Load", "", time.Now())
+	_, err := s.DB.ExecEx(ctx, `
+TRUNCATE users, forums, threads, posts, post_revisions, votes, post_votes, forum_users, forum_moderators;
+`, nil)
 	if err != nil {
+		utils.Logger(ctx).WithError(err).Error("truncate failed")
 		return models.NewError(models.InternalDatabase, err.Error())
 	}
 
 	return nil
 }
 
+// Ready проверка для readiness пробы: из пула можно получить соединение,
+// postgres отвечает на ping и все миграции бинарника применены.
+// Acquire у пула не умеет в context, поэтому ждём его в отдельной горутине.
+func (s *DBService) Ready(ctx context.Context) error {
+	type acquired struct {
+		conn *pgx.Conn
+		err  error
+	}
+	result := make(chan acquired, 1)
+	go func() {
+		conn, err := s.DB.Acquire()
+		result <- acquired{conn: conn, err: err}
+	}()
+
+	var conn *pgx.Conn
+	select {
+	case <-ctx.Done():
+		go func() {
+			if late := <-result; late.err == nil {
+				s.DB.Release(late.conn)
+			}
+		}()
+		return fmt.Errorf("acquire connection: %s", ctx.Err())
+	case a := <-result:
+		if a.err != nil {
+			return fmt.Errorf("acquire connection: %s", a.err)
+		}
+		conn = a.conn
+	}
+	defer s.DB.Release(conn)
+
+	if err := conn.Ping(ctx); err != nil {
+		return fmt.Errorf("ping: %s", err)
+	}
+
+	var version int
+	if err := conn.QueryRowEx(ctx, getSchemaVersionSQL, nil).Scan(&version); err != nil {
+		return fmt.Errorf("schema version: %s", err)
+	}
+	if latest := LatestMigration(); version < latest {
+		return fmt.Errorf("schema is at version %d, binary expects %d", version, latest)
+	}
+
+	return nil
+}
+
 func ErrorCode(err error) (string) {
 	pgerr, ok := err.(pgx.PgError)
 	if !ok {
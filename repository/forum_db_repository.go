@@ -1,16 +1,31 @@
 package repository
 
 import (
+	"context"
+	"fmt"
+	"github.com/AntonPriyma/db_forum/metrics"
 	"github.com/AntonPriyma/db_forum/models"
+	"github.com/AntonPriyma/db_forum/utils"
 	"github.com/jackc/pgx"
-	"log"
+	"time"
 )
 
 
 type ForumRepository interface {
-	Create(forum *models.Forum) (*models.Forum, error)
-	GetForumBySlug(slug string) (*models.Forum, error)
-	GetForumUsersDB(slug, limit, since, desc string) (*models.Users, error)
+	Create(ctx context.Context, forum *models.Forum) (*models.Forum, error)
+	GetForumBySlug(ctx context.Context, slug string) (*models.Forum, error)
+	GetForumUsersDB(ctx context.Context, slug, limit, since, desc string) (*models.Users, error)
+	// GetForums список форумов, since - slug последнего форума предыдущей страницы
+	GetForums(ctx context.Context, limit, since, sort, desc string) (*models.Forums, error)
+	// Update меняет название, владельца и слаг форума, пустой update возвращает форум как есть
+	Update(ctx context.Context, slug string, update *models.ForumUpdate) (*models.Forum, error)
+	// Delete при archive закрывает форум и его подфорумы для записи, иначе удаляет его целиком.
+	// Форум с подфорумами удалить нельзя, только архивировать.
+	Delete(ctx context.Context, slug string, archive bool) (*models.ForumDeleted, error)
+	// GetChildren прямые подфорумы по slug
+	GetChildren(ctx context.Context, slug string) (*models.Forums, error)
+	// GetBreadcrumbs предки форума от корня до родителя
+	GetBreadcrumbs(ctx context.Context, slug string) ([]models.ForumCrumb, error)
 }
 
 type ForumRepositoryImpl struct{
@@ -38,21 +53,22 @@ var queryForumUserNoSince = map[string]string{
 	"false": getForumUsersSQl,
 }
 
-func (r *ForumRepositoryImpl) GetForumUsersDB(slug, limit, since, desc string) (*models.Users, error) {
+func (r *ForumRepositoryImpl) GetForumUsersDB(ctx context.Context, slug, limit, since, desc string) (*models.Users, error) {
+	defer metrics.ObserveQuery("forums", "GetForumUsersDB", sortVariant("", desc, since), time.Now())
 	var rows *pgx.Rows
 	var err error
 
 	if since != "" {
 		query := queryForumUserWithSince[desc]
-		rows, err = r.db.Query(query, slug, since, limit)
+		rows, err = r.db.QueryEx(ctx, query, nil, slug, since, limit)
 	} else {
 		query := queryForumUserNoSince[desc]
-		rows, err = r.db.Query(query, slug, limit)
+		rows, err = r.db.QueryEx(ctx, query, nil, slug, limit)
 	}
 	defer rows.Close()
 
 	if err != nil {
-		log.Println(err)
+		utils.Logger(ctx).WithError(err).Error("forum users query failed")
 		return nil, models.ForumNotFound
 	}
 
@@ -64,12 +80,13 @@ func (r *ForumRepositoryImpl) GetForumUsersDB(slug, limit, since, desc string) (
 			&u.Fullname,
 			&u.About,
 			&u.Email,
+			&u.Karma,
 		)
 		users = append(users, &u)
 	}
 
 	if len(users) == 0 {
-		_, err := r.GetForumBySlug(slug)
+		_, err := r.GetForumBySlug(ctx, slug)
 		if err != nil {
 			return nil, models.ForumNotFound
 		}
@@ -77,27 +94,96 @@ func (r *ForumRepositoryImpl) GetForumUsersDB(slug, limit, since, desc string) (
 	return &users, nil
 }
 
+// getForumsSQL собирается из шаблонов ниже, sort проверен models.IsForumSort
+func getForumsSQL(sort, desc, since string) string {
+	order, cmp := "ASC", ">"
+	if desc == "true" {
+		order, cmp = "DESC", "<"
+	}
+	column := sort
+	if sort == models.ForumSortTitle {
+		// title обычный TEXT, регистр сбрасываем как у CITEXT slug
+		column = "lower(title)"
+	}
+	where := ""
+	switch {
+	case since == "":
+	case sort == models.ForumSortSlug:
+		where = fmt.Sprintf(getForumsSinceSlugSQL, cmp)
+	default:
+		where = fmt.Sprintf(getForumsSinceSQL, column, cmp, column)
+	}
+	return fmt.Sprintf(getForumsTemplateSQL, where, column, order, order)
+}
+
+func (r *ForumRepositoryImpl) GetForums(ctx context.Context, limit, since, sort, desc string) (*models.Forums, error) {
+	defer metrics.ObserveQuery("forums", "GetForums", sortVariant(sort, desc, since), time.Now())
+	var rows *pgx.Rows
+	var err error
+
+	query := getForumsSQL(sort, desc, since)
+	if since != "" {
+		rows, err = r.db.QueryEx(ctx, query, nil, limit, since)
+	} else {
+		rows, err = r.db.QueryEx(ctx, query, nil, limit)
+	}
+	if err != nil {
+		utils.Logger(ctx).WithError(err).Error("forums query failed")
+		return nil, err
+	}
+	defer rows.Close()
+
+	forums := models.Forums{}
+	for rows.Next() {
+		f := models.Forum{}
+		err = rows.Scan(
+			&f.Slug,
+			&f.Title,
+			&f.Owner,
+			&f.Posts,
+			&f.Threads,
+			&f.Archived,
+			&f.Parent,
+		)
+		if err != nil {
+			return nil, err
+		}
+		forums = append(forums, &f)
+	}
+	if err = rows.Err(); err != nil {
+		utils.Logger(ctx).WithError(err).Error("forums query failed")
+		return nil, err
+	}
+
+	return &forums, nil
+}
+
 func NewForumRepositoryImpl(db *pgx.ConnPool) ForumRepository {
 	return &ForumRepositoryImpl{db: db}
 }
 
-func (r *ForumRepositoryImpl) Create(forum *models.Forum) (*models.Forum, error) {
-	err := r.db.QueryRow(
-		createForumSQL,
+func (r *ForumRepositoryImpl) Create(ctx context.Context, forum *models.Forum) (*models.Forum, error) {
+	defer metrics.ObserveQuery("forums", "Create", "", time.Now())
+	err := r.db.QueryRowEx(ctx,
+		createForumSQL, nil,
 		&forum.Slug,
 		&forum.Title,
 		&forum.Owner,
-	).Scan(&forum.Owner)
+		&forum.Parent,
+	).Scan(&forum.Owner, &forum.Parent)
 
 	switch ErrorCode(err) {
 	case models.PgxOK:
 		return forum, nil
 	case models.PgxErrUnique:
-		forum, _ := r.GetForumBySlug(forum.Slug)
+		forum, _ := r.GetForumBySlug(ctx, forum.Slug)
 		return forum, models.ForumIsExist
 	case models.PgxErrNotNull:
 		return nil, models.UserNotFound
+	case models.PgxErrForeignKey:
+		return nil, models.ForumParentNotFound
 	default:
+		utils.Logger(ctx).WithError(err).WithField("forum", forum.Slug).Error("forum insert failed")
 		return nil, err
 	}
 }
@@ -105,11 +191,12 @@ func (r *ForumRepositoryImpl) Create(forum *models.Forum) (*models.Forum, error)
 
 
 
-func (r *ForumRepositoryImpl) GetForumBySlug(slug string) (*models.Forum, error) {
+func (r *ForumRepositoryImpl) GetForumBySlug(ctx context.Context, slug string) (*models.Forum, error) {
+	defer metrics.ObserveQuery("forums", "GetForumBySlug", "", time.Now())
 	f := models.Forum{}
 
-	err := r.db.QueryRow(
-		getForumSQL,
+	err := r.db.QueryRowEx(ctx,
+		getForumSQL, nil,
 		slug,
 	).Scan(
 		&f.Slug,
@@ -117,6 +204,8 @@ func (r *ForumRepositoryImpl) GetForumBySlug(slug string) (*models.Forum, error)
 		&f.Owner,
 		&f.Posts,
 		&f.Threads,
+		&f.Archived,
+		&f.Parent,
 	)
 
 	if err != nil {
@@ -126,3 +215,188 @@ func (r *ForumRepositoryImpl) GetForumBySlug(slug string) (*models.Forum, error)
 	return &f, nil
 }
 
+func (r *ForumRepositoryImpl) Update(ctx context.Context, slug string, update *models.ForumUpdate) (*models.Forum, error) {
+	defer metrics.ObserveQuery("forums", "Update", "", time.Now())
+	if update.IsEmpty() {
+		return r.GetForumBySlug(ctx, slug)
+	}
+
+	tx, err := r.db.BeginEx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	f := models.Forum{}
+	err = tx.QueryRowEx(ctx, updateForumSQL, nil,
+		slug,
+		update.Title,
+		update.Owner,
+		update.Slug,
+	).Scan(
+		&f.Slug,
+		&f.Title,
+		&f.Owner,
+		&f.Posts,
+		&f.Threads,
+		&f.Archived,
+		&f.Parent,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, models.ForumNotFound
+	}
+	if err != nil {
+		switch ErrorCode(err) {
+		case models.PgxErrNotNull:
+			return nil, models.UserNotFound
+		case models.PgxErrUnique:
+			tx.Rollback()
+			existing, err := r.GetForumBySlug(ctx, update.Slug)
+			if err != nil {
+				return nil, err
+			}
+			return existing, models.ForumIsExist
+		}
+		utils.Logger(ctx).WithError(err).WithField("forum", slug).Error("forum update failed")
+		return nil, err
+	}
+
+	// threads и posts переехали через ON UPDATE CASCADE. forum_users - денормализованная
+	// UNLOGGED копия, которую пополняет каждая вставка треда и пачки постов, и проверка
+	// внешнего ключа там лишняя, так что слаг в ней меняем сами в той же транзакции
+	if update.Slug != "" {
+		if _, err = tx.ExecEx(ctx, renameForumUsersSQL, nil, slug, f.Slug); err != nil {
+			utils.Logger(ctx).WithError(err).WithField("forum", slug).Error("forum users rename failed")
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		utils.Logger(ctx).WithError(err).WithField("forum", slug).Error("forum update failed")
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+func (r *ForumRepositoryImpl) Delete(ctx context.Context, slug string, archive bool) (*models.ForumDeleted, error) {
+	variant := "remove"
+	if archive {
+		variant = "archive"
+	}
+	defer metrics.ObserveQuery("forums", "Delete", variant, time.Now())
+
+	tx, err := r.db.BeginEx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &models.ForumDeleted{Archived: archive}
+	var parent string
+	var hasChildren bool
+	var threads int32
+	var posts int64
+	if err = tx.QueryRowEx(ctx, lockForumSQL, nil, slug).Scan(&result.Forum, &parent, &hasChildren, &threads, &posts); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, models.ForumNotFound
+		}
+		return nil, err
+	}
+	if hasChildren && !archive {
+		return nil, models.ForumHasChildren
+	}
+
+	statements := []string{deleteForumVotesSQL, deleteForumPostsSQL, deleteForumThreadsSQL, deleteForumUsersSQL, deleteForumSQL}
+	if archive {
+		// при архивации ничего не удаляется, в ответе всё, что стало только для чтения
+		statements = []string{archiveForumSQL}
+		result.Threads, result.Posts = int64(threads), posts
+	}
+	for _, statement := range statements {
+		tag, err := tx.ExecEx(ctx, statement, nil, result.Forum)
+		if err != nil {
+			utils.Logger(ctx).WithError(err).WithField("forum", slug).Error("forum delete failed")
+			return nil, err
+		}
+		switch statement {
+		case deleteForumThreadsSQL:
+			result.Threads = tag.RowsAffected()
+		case deleteForumPostsSQL:
+			result.Posts = tag.RowsAffected()
+		}
+	}
+	// удалённое больше не входит в счётчики предков
+	if parent != "" && !archive {
+		if _, err = tx.ExecEx(ctx, updateForumCountersSQL, nil, -result.Threads, -result.Posts, parent); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		utils.Logger(ctx).WithError(err).WithField("forum", slug).Error("forum delete failed")
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *ForumRepositoryImpl) GetChildren(ctx context.Context, slug string) (*models.Forums, error) {
+	defer metrics.ObserveQuery("forums", "GetChildren", "", time.Now())
+	rows, err := r.db.QueryEx(ctx, getForumChildrenSQL, nil, slug)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).Error("forum children query failed")
+		return nil, err
+	}
+	defer rows.Close()
+
+	forums := models.Forums{}
+	for rows.Next() {
+		f := models.Forum{}
+		err = rows.Scan(
+			&f.Slug,
+			&f.Title,
+			&f.Owner,
+			&f.Posts,
+			&f.Threads,
+			&f.Archived,
+			&f.Parent,
+		)
+		if err != nil {
+			return nil, err
+		}
+		forums = append(forums, &f)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(forums) == 0 {
+		if _, err := r.GetForumBySlug(ctx, slug); err != nil {
+			return nil, models.ForumNotFound
+		}
+	}
+	return &forums, nil
+}
+
+func (r *ForumRepositoryImpl) GetBreadcrumbs(ctx context.Context, slug string) ([]models.ForumCrumb, error) {
+	defer metrics.ObserveQuery("forums", "GetBreadcrumbs", "", time.Now())
+	rows, err := r.db.QueryEx(ctx, getForumBreadcrumbsSQL, nil, slug)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).Error("forum breadcrumbs query failed")
+		return nil, err
+	}
+	defer rows.Close()
+
+	crumbs := make([]models.ForumCrumb, 0)
+	for rows.Next() {
+		crumb := models.ForumCrumb{}
+		if err = rows.Scan(&crumb.Slug, &crumb.Title); err != nil {
+			return nil, err
+		}
+		crumbs = append(crumbs, crumb)
+	}
+	return crumbs, rows.Err()
+}
+
@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"context"
+	"github.com/AntonPriyma/db_forum/models"
+	"sort"
+	"strings"
+)
+
+type ForumMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewForumMemoryRepository(store *MemoryStore) ForumRepository {
+	return &ForumMemoryRepository{store: store}
+}
+
+func (r *ForumMemoryRepository) Create(ctx context.Context, forum *models.Forum) (*models.Forum, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	owner, ok := r.store.users[key(forum.Owner)]
+	if !ok {
+		return nil, models.UserNotFound
+	}
+	if existing, ok := r.store.forums[key(forum.Slug)]; ok {
+		copied := *existing
+		return &copied, models.ForumIsExist
+	}
+	if forum.Parent != "" {
+		parent, ok := r.store.forums[key(forum.Parent)]
+		if !ok {
+			return nil, models.ForumParentNotFound
+		}
+		forum.Parent = parent.Slug
+	}
+
+	forum.Owner = owner.Nickname
+	created := *forum
+	r.store.forums[key(forum.Slug)] = &created
+	return forum, nil
+}
+
+func (r *ForumMemoryRepository) GetForumBySlug(ctx context.Context, slug string) (*models.Forum, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	existing, ok := r.store.forums[key(slug)]
+	if !ok {
+		return nil, models.ForumNotFound
+	}
+	forum := *existing
+	return &forum, nil
+}
+
+func (r *ForumMemoryRepository) GetForumUsersDB(ctx context.Context, slug, limit, since, desc string) (*models.Users, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	n, err := parseLimit(limit)
+	if err != nil {
+		return nil, models.ForumNotFound
+	}
+	if desc != "true" && desc != "false" {
+		return nil, models.ForumNotFound
+	}
+
+	// forum_user CITEXT COLLATE ucs_basic: сравнение побайтово по нижнему регистру
+	members := make([]*models.User, 0, len(r.store.forumUsers[key(slug)]))
+	for _, user := range r.store.forumUsers[key(slug)] {
+		if since != "" {
+			if desc == "true" && key(user.Nickname) >= key(since) {
+				continue
+			}
+			if desc == "false" && key(user.Nickname) <= key(since) {
+				continue
+			}
+		}
+		members = append(members, user)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if desc == "true" {
+			return key(members[i].Nickname) > key(members[j].Nickname)
+		}
+		return key(members[i].Nickname) < key(members[j].Nickname)
+	})
+	if len(members) > n {
+		members = members[:n]
+	}
+
+	users := models.Users{}
+	for _, member := range members {
+		copied := *member
+		// карма не копируется в forum_users, а берётся из users
+		if user, ok := r.store.users[key(member.Nickname)]; ok {
+			copied.Karma = user.Karma
+		}
+		users = append(users, &copied)
+	}
+
+	if len(users) == 0 {
+		if _, ok := r.store.forums[key(slug)]; !ok {
+			return nil, models.ForumNotFound
+		}
+	}
+	return &users, nil
+}
+
+func (r *ForumMemoryRepository) Update(ctx context.Context, slug string, update *models.ForumUpdate) (*models.Forum, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.forums[key(slug)]
+	if !ok {
+		return nil, models.ForumNotFound
+	}
+
+	var owner *models.User
+	if update.Owner != "" {
+		if owner, ok = r.store.users[key(update.Owner)]; !ok {
+			return nil, models.UserNotFound
+		}
+	}
+	if update.Slug != "" && key(update.Slug) != key(existing.Slug) {
+		if conflict, ok := r.store.forums[key(update.Slug)]; ok {
+			copied := *conflict
+			return &copied, models.ForumIsExist
+		}
+		r.store.renameForum(existing, update.Slug)
+	}
+	if update.Title != "" {
+		existing.Title = update.Title
+	}
+	if owner != nil {
+		existing.Owner = owner.Nickname
+	}
+
+	forum := *existing
+	return &forum, nil
+}
+
+func (r *ForumMemoryRepository) Delete(ctx context.Context, slug string, archive bool) (*models.ForumDeleted, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	forum, ok := r.store.forums[key(slug)]
+	if !ok {
+		return nil, models.ForumNotFound
+	}
+	result := &models.ForumDeleted{Forum: forum.Slug, Archived: archive}
+	if archive {
+		// archiveForumSQL: флаг на форуме и подфорумах, треды и посты не трогаются
+		r.archiveSubtree(forum.Slug)
+		result.Threads, result.Posts = int64(forum.Threads), forum.Posts
+		return result, nil
+	}
+	for _, child := range r.store.forums {
+		if key(child.Parent) == key(forum.Slug) {
+			return nil, models.ForumHasChildren
+		}
+	}
+
+	for id, thread := range r.store.threads {
+		if key(thread.Forum) != key(forum.Slug) {
+			continue
+		}
+		posts := r.store.threadPosts[id]
+		for _, post := range posts {
+			r.store.removePostVotes(post)
+			delete(r.store.posts, post.ID)
+		}
+		result.Posts += int64(len(posts))
+		result.Threads++
+		delete(r.store.threadPosts, id)
+		r.store.removeThreadVotes(thread)
+		delete(r.store.threads, id)
+		if thread.Slug != "" {
+			delete(r.store.threadSlug, key(thread.Slug))
+		}
+	}
+
+	r.store.addForumCounters(forum.Parent, -int32(result.Threads), -result.Posts)
+	delete(r.store.forumUsers, key(forum.Slug))
+	delete(r.store.moderators, key(forum.Slug))
+	delete(r.store.forums, key(forum.Slug))
+	return result, nil
+}
+
+// archiveSubtree помечает архивным форум slug и всех его потомков
+func (r *ForumMemoryRepository) archiveSubtree(slug string) {
+	r.store.forums[key(slug)].Archived = true
+	for _, child := range r.store.forums {
+		if key(child.Parent) == key(slug) && !child.Archived {
+			r.archiveSubtree(child.Slug)
+		}
+	}
+}
+
+func (r *ForumMemoryRepository) GetForums(ctx context.Context, limit, since, sortBy, desc string) (*models.Forums, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	n, err := parseLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// compare как ORDER BY <sort>, slug: title и CITEXT slug сравниваются по нижнему регистру
+	compare := func(a, b *models.Forum) int {
+		var c int
+		switch sortBy {
+		case models.ForumSortTitle:
+			c = strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		case models.ForumSortThreads:
+			c = compareInt64(int64(a.Threads), int64(b.Threads))
+		case models.ForumSortPosts:
+			c = compareInt64(a.Posts, b.Posts)
+		}
+		if c == 0 {
+			c = strings.Compare(key(a.Slug), key(b.Slug))
+		}
+		if desc == "true" {
+			c = -c
+		}
+		return c
+	}
+
+	var after *models.Forum
+	if since != "" {
+		if sortBy == models.ForumSortSlug {
+			after = &models.Forum{Slug: since}
+		} else if after = r.store.forums[key(since)]; after == nil {
+			return &models.Forums{}, nil
+		}
+	}
+
+	found := make([]*models.Forum, 0, len(r.store.forums))
+	for _, forum := range r.store.forums {
+		if after == nil || compare(forum, after) > 0 {
+			found = append(found, forum)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return compare(found[i], found[j]) < 0
+	})
+	if len(found) > n {
+		found = found[:n]
+	}
+
+	forums := models.Forums{}
+	for _, forum := range found {
+		copied := *forum
+		forums = append(forums, &copied)
+	}
+	return &forums, nil
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (r *ForumMemoryRepository) GetChildren(ctx context.Context, slug string) (*models.Forums, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if _, ok := r.store.forums[key(slug)]; !ok {
+		return nil, models.ForumNotFound
+	}
+
+	forums := models.Forums{}
+	for _, forum := range r.store.forums {
+		if key(forum.Parent) == key(slug) {
+			copied := *forum
+			forums = append(forums, &copied)
+		}
+	}
+	sort.Slice(forums, func(i, j int) bool {
+		return key(forums[i].Slug) < key(forums[j].Slug)
+	})
+	return &forums, nil
+}
+
+func (r *ForumMemoryRepository) GetBreadcrumbs(ctx context.Context, slug string) ([]models.ForumCrumb, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	crumbs := make([]models.ForumCrumb, 0)
+	forum, ok := r.store.forums[key(slug)]
+	if !ok {
+		return crumbs, nil
+	}
+	for parent := r.store.forums[key(forum.Parent)]; parent != nil; parent = r.store.forums[key(parent.Parent)] {
+		crumbs = append([]models.ForumCrumb{{Slug: parent.Slug, Title: parent.Title}}, crumbs...)
+	}
+	return crumbs, nil
+}
+
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/AntonPriyma/db_forum/models"
+)
+
+type MemoryService struct {
+	store *MemoryStore
+}
+
+func NewMemoryService(store *MemoryStore) ServiceRepository {
+	return &MemoryService{store: store}
+}
+
+func (s *MemoryService) GetStatus(ctx context.Context) (*models.Status, *models.Error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	return &models.Status{
+		Forum:  int64(len(s.store.forums)),
+		Post:   int64(len(s.store.posts)),
+		Thread: int64(len(s.store.threads)),
+		User:   int64(len(s.store.users)),
+	}, nil
+}
+
+// Load аналог TRUNCATE, счётчики id при этом не сбрасываются, как и sequence в postgres
+func (s *MemoryService) Load(ctx context.Context) *models.Error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	s.store.reset()
+	return nil
+}
+
+func (s *MemoryService) Ready(ctx context.Context) error {
+	return nil
+}
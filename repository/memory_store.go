@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/AntonPriyma/db_forum/models"
+)
+
+// MemoryStore общее состояние in-memory бэкенда, аналог базы для всех memory репозиториев.
+// Ключи по nickname и slug хранятся в нижнем регистре, как сравнивает CITEXT.
+// Один мьютекс на всё хранилище, как одна транзакция на запрос.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	users      map[string]*models.User
+	forums     map[string]*models.Forum
+	threads    map[int32]*models.Thread
+	threadSlug map[string]int32
+	posts      map[int64]*memoryPost
+	// посты треда в порядке вставки, то есть по id
+	threadPosts map[int32][]*memoryPost
+	// thread -> nickname -> voice
+	votes map[int32]map[string]int32
+	// forum -> nickname -> пользователь на момент первого поста/треда, как forum_users
+	forumUsers map[string]map[string]*models.User
+	// nickname -> bcrypt хэш, отдельно от users, чтобы копии пользователей его не выносили
+	passwords map[string]string
+	// nickname администраторов, как users.is_admin
+	admins map[string]bool
+	// forum -> nickname модераторов, как forum_moderators
+	moderators map[string]map[string]bool
+	// post -> nickname -> voice, как post_votes
+	postVotes map[int64]map[string]int32
+
+	lastThreadID int32
+	lastPostID   int64
+}
+
+// memoryPost пост вместе с материализованным путём, как колонка path, и историей правок, как post_revisions
+type memoryPost struct {
+	models.Post
+	path      []int64
+	revisions models.PostRevisions
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{}
+	s.reset()
+	return s
+}
+
+func (s *MemoryStore) reset() {
+	s.users = map[string]*models.User{}
+	s.passwords = map[string]string{}
+	s.admins = map[string]bool{}
+	s.moderators = map[string]map[string]bool{}
+	s.forums = map[string]*models.Forum{}
+	s.threads = map[int32]*models.Thread{}
+	s.threadSlug = map[string]int32{}
+	s.posts = map[int64]*memoryPost{}
+	s.threadPosts = map[int32][]*memoryPost{}
+	s.votes = map[int32]map[string]int32{}
+	s.postVotes = map[int64]map[string]int32{}
+	s.forumUsers = map[string]map[string]*models.User{}
+}
+
+func key(s string) string {
+	return strings.ToLower(s)
+}
+
+// addForumUser аналог триггера add_forum_user и вставки в forum_users при создании постов
+func (s *MemoryStore) addForumUser(forum, nickname string) {
+	user, ok := s.users[key(nickname)]
+	if !ok {
+		return
+	}
+	members, ok := s.forumUsers[key(forum)]
+	if !ok {
+		members = map[string]*models.User{}
+		s.forumUsers[key(forum)] = members
+	}
+	if _, ok := members[key(nickname)]; !ok {
+		copied := *user
+		members[key(nickname)] = &copied
+	}
+}
+
+// addKarma аналог правки users.karma в триггерах post_vote и thread_vote_karma
+func (s *MemoryStore) addKarma(nickname string, delta int32) {
+	if user, ok := s.users[key(nickname)]; ok {
+		user.Karma += delta
+	}
+}
+
+// removePostVotes голоса удаляемого поста вместе с кармой автора, как ON DELETE CASCADE на post_votes
+func (s *MemoryStore) removePostVotes(post *memoryPost) {
+	s.addKarma(post.Author, -post.Votes)
+	delete(s.postVotes, post.ID)
+}
+
+// removeThreadVotes голоса удаляемого треда вместе с кармой автора, как deleteThreadVotesSQL
+func (s *MemoryStore) removeThreadVotes(thread *models.Thread) {
+	s.addKarma(thread.Author, -thread.Votes)
+	delete(s.votes, thread.ID)
+}
+
+// addForumCounters изменение счётчиков форума и всех его предков, как forum_lineage
+func (s *MemoryStore) addForumCounters(slug string, threads int32, posts int64) {
+	for forum := s.forums[key(slug)]; forum != nil; forum = s.forums[key(forum.Parent)] {
+		forum.Threads += threads
+		forum.Posts += posts
+	}
+}
+
+// renameForum переносит форум на новый слаг вместе с тредами, постами и forum_users, как ON UPDATE CASCADE
+func (s *MemoryStore) renameForum(forum *models.Forum, slug string) {
+	old := key(forum.Slug)
+	for _, thread := range s.threads {
+		if key(thread.Forum) == old {
+			thread.Forum = slug
+		}
+	}
+	for _, post := range s.posts {
+		if key(post.Forum) == old {
+			post.Forum = slug
+		}
+	}
+	for _, child := range s.forums {
+		if key(child.Parent) == old {
+			child.Parent = slug
+		}
+	}
+	if members, ok := s.forumUsers[old]; ok {
+		delete(s.forumUsers, old)
+		s.forumUsers[key(slug)] = members
+	}
+	if moderators, ok := s.moderators[old]; ok {
+		delete(s.moderators, old)
+		s.moderators[key(slug)] = moderators
+	}
+	delete(s.forums, old)
+	s.forums[key(slug)] = forum
+	forum.Slug = slug
+}
+
+// threadByParam поиск треда по id или slug, как в GetThread
+func (s *MemoryStore) threadByParam(param string) (*models.Thread, bool) {
+	if isNumber(param) {
+		id, err := strconv.ParseInt(param, 10, 32)
+		if err != nil {
+			return nil, false
+		}
+		thread, ok := s.threads[int32(id)]
+		return thread, ok
+	}
+
+	id, ok := s.threadSlug[key(param)]
+	if !ok {
+		return nil, false
+	}
+	return s.threads[id], true
+}
+
+// comparePaths сравнение path как bigint[] в postgres: поэлементно, более короткий префикс меньше
+func comparePaths(a, b []int64) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// parseLimit разбор limit так же строго, как $n::TEXT::INTEGER
+func parseLimit(limit string) (int, error) {
+	n, err := strconv.Atoi(limit)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("LIMIT must not be negative")
+	}
+	return n, nil
+}
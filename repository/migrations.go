@@ -0,0 +1,650 @@
+package repository
+
+// Migration версионированное изменение схемы.
+// Миграции применяются строго по возрастанию Version, новые добавляются только в конец списка.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "init",
+		Up:      initSchemaUpSQL,
+		Down:    initSchemaDownSQL,
+	},
+	{
+		Version: 2,
+		Name:    "soft_delete",
+		Up:      softDeleteUpSQL,
+		Down:    softDeleteDownSQL,
+	},
+	{
+		Version: 3,
+		Name:    "post_revisions",
+		Up:      postRevisionsUpSQL,
+		Down:    postRevisionsDownSQL,
+	},
+	{
+		Version: 4,
+		Name:    "thread_slug_unique",
+		Up:      threadSlugUniqueUpSQL,
+		Down:    threadSlugUniqueDownSQL,
+	},
+	{
+		Version: 5,
+		Name:    "forum_rename_archive",
+		Up:      forumRenameArchiveUpSQL,
+		Down:    forumRenameArchiveDownSQL,
+	},
+	{
+		Version: 6,
+		Name:    "sub_forums",
+		Up:      subForumsUpSQL,
+		Down:    subForumsDownSQL,
+	},
+	{
+		Version: 7,
+		Name:    "search",
+		Up:      searchUpSQL,
+		Down:    searchDownSQL,
+	},
+	{
+		Version: 8,
+		Name:    "users_directory",
+		Up:      usersDirectoryUpSQL,
+		Down:    usersDirectoryDownSQL,
+	},
+	{
+		Version: 9,
+		Name:    "user_activity",
+		Up:      userActivityUpSQL,
+		Down:    userActivityDownSQL,
+	},
+	{
+		Version: 10,
+		Name:    "user_passwords",
+		Up:      userPasswordsUpSQL,
+		Down:    userPasswordsDownSQL,
+	},
+	{
+		Version: 11,
+		Name:    "roles",
+		Up:      rolesUpSQL,
+		Down:    rolesDownSQL,
+	},
+	{
+		Version: 12,
+		Name:    "vote_retract",
+		Up:      voteRetractUpSQL,
+		Down:    voteRetractDownSQL,
+	},
+	{
+		Version: 13,
+		Name:    "post_votes",
+		Up:      postVotesUpSQL,
+		Down:    postVotesDownSQL,
+	},
+	{
+		Version: 14,
+		Name:    "forum_archived",
+		Up:      forumArchivedUpSQL,
+		Down:    forumArchivedDownSQL,
+	},
+	{
+		Version: 15,
+		Name:    "forum_lineage_guard",
+		Up:      forumLineageGuardUpSQL,
+		Down:    forumLineageGuardDownSQL,
+	},
+}
+
+// Migrations список всех известных бинарнику миграций
+func Migrations() []Migration {
+	return migrations
+}
+
+// LatestMigration версия последней миграции, до которой должна быть докачена база
+func LatestMigration() int {
+	return migrations[len(migrations)-1].Version
+}
+
+const (
+	// схема, которая раньше накатывалась через psql из sql/init.sql в Dockerfile.
+	// Всё через IF NOT EXISTS, чтобы на уже существующей базе миграция просто встала на учёт.
+	initSchemaUpSQL = `
+CREATE EXTENSION IF NOT EXISTS citext;
+
+CREATE UNLOGGED TABLE IF NOT EXISTS users
+(
+    "nickname" CITEXT PRIMARY KEY,
+    "email"    CITEXT UNIQUE NOT NULL,
+    "fullname" CITEXT        NOT NULL,
+    "about"    TEXT
+);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS forums
+(
+    "posts"   BIGINT  DEFAULT 0,
+    "slug"    CITEXT UNIQUE NOT NULL,
+    "threads" INTEGER DEFAULT 0,
+    "title"   TEXT          NOT NULL,
+    "user"    CITEXT        NOT NULL REFERENCES users ("nickname")
+);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS threads
+(
+    "id"      SERIAL PRIMARY KEY,
+    "author"  CITEXT NOT NULL REFERENCES users ("nickname"),
+    "created" TIMESTAMPTZ(3) DEFAULT now(),
+    "forum"   CITEXT NOT NULL REFERENCES forums ("slug"),
+    "message" TEXT   NOT NULL,
+    "slug"    CITEXT,
+    "title"   TEXT   NOT NULL,
+    "votes"   INTEGER        DEFAULT 0
+);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS posts
+(
+    "id"       BIGSERIAL PRIMARY KEY,
+    "author"   CITEXT  NOT NULL REFERENCES users ("nickname"),
+    "created"  TIMESTAMPTZ(3) DEFAULT now(),
+    "forum"    CITEXT  NOT NULL REFERENCES forums ("slug"),
+    "isEdited" BOOLEAN        DEFAULT FALSE,
+    "message"  TEXT    NOT NULL,
+    "parent"   INTEGER        DEFAULT 0,
+    "thread"   INTEGER NOT NULL REFERENCES threads ("id"),
+    "path"     BIGINT[]
+);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS votes
+(
+    "thread"   INT     NOT NULL REFERENCES threads ("id"),
+    "voice"    INTEGER NOT NULL,
+    "nickname" CITEXT  NOT NULL
+);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS forum_users
+(
+    "forum_user" CITEXT COLLATE ucs_basic NOT NULL,
+    "forum"      CITEXT                   NOT NULL,
+    "email"      TEXT                     NOT NULL,
+    "fullname"   TEXT                     NOT NULL,
+    "about"      TEXT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_fu_user ON forum_users (forum, forum_user);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_forum_slug  ON forums (slug);
+
+CREATE INDEX IF NOT EXISTS idx_threads_slug ON threads (slug);
+CREATE INDEX IF NOT EXISTS idx_threads_forum ON threads (forum);
+
+CREATE INDEX IF NOT EXISTS idx_posts_forum ON posts (forum);
+CREATE INDEX IF NOT EXISTS idx_posts_thread_path ON posts (thread, path);
+CREATE INDEX IF NOT EXISTS idx_posts_thread_id ON posts (thread, id);
+CREATE INDEX IF NOT EXISTS idx_posts_thread_id0 ON posts (thread, id) WHERE parent = 0;
+CREATE INDEX IF NOT EXISTS idx_posts_thread_id_created ON posts (id, created, thread);
+CREATE INDEX IF NOT EXISTS idx_posts_thread_path1_id ON posts (thread, (path[1]), id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_votes_thread_nickname ON votes (thread, nickname);
+
+CREATE OR REPLACE FUNCTION insert_vote() RETURNS TRIGGER AS
+$insert_vote$
+BEGIN
+    UPDATE threads
+    SET votes = votes + NEW.voice
+    WHERE id = NEW.thread;
+    RETURN NEW;
+END;
+$insert_vote$
+    LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS insert_vote ON votes;
+CREATE TRIGGER insert_vote
+    BEFORE INSERT
+    ON votes
+    FOR EACH ROW
+EXECUTE PROCEDURE insert_vote();
+
+CREATE OR REPLACE FUNCTION update_vote() RETURNS TRIGGER AS
+$update_vote$
+BEGIN
+    UPDATE threads
+    SET votes = votes - OLD.voice + NEW.voice
+    WHERE id = NEW.thread;
+    RETURN NEW;
+END;
+$update_vote$
+    LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS update_vote ON votes;
+CREATE TRIGGER update_vote
+    BEFORE UPDATE
+    ON votes
+    FOR EACH ROW
+EXECUTE PROCEDURE update_vote();
+
+CREATE OR REPLACE FUNCTION thread_insert() RETURNS trigger AS
+$thread_insert$
+BEGIN
+    UPDATE forums
+    SET threads = threads + 1
+    WHERE slug = NEW.forum;
+    RETURN NULL;
+END;
+$thread_insert$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS thread_insert ON threads;
+CREATE TRIGGER thread_insert
+    AFTER INSERT
+    ON threads
+    FOR EACH ROW
+EXECUTE PROCEDURE thread_insert();
+
+CREATE OR REPLACE FUNCTION add_forum_user() RETURNS TRIGGER AS
+$add_forum_user$
+BEGIN
+    INSERT INTO forum_users ("forum_user", "forum", "email", "fullname", "about")
+    SELECT nickname, NEW.forum, email, fullname, about
+    FROM users
+    WHERE nickname = NEW.author
+    ON CONFLICT DO NOTHING;
+    RETURN NULL;
+END;
+$add_forum_user$
+    LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS add_forum_user ON threads;
+CREATE TRIGGER add_forum_user
+    AFTER INSERT
+    ON threads
+    FOR EACH ROW
+EXECUTE PROCEDURE add_forum_user();
+`
+	initSchemaDownSQL = `
+DROP TABLE IF EXISTS forum_users, votes, posts, threads, forums, users CASCADE;
+DROP FUNCTION IF EXISTS insert_vote(), update_vote(), thread_insert(), add_forum_user();
+`
+
+	// скрытие постов и тредов модераторами, строки остаются на месте
+	softDeleteUpSQL = `
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS "deleted"    BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS "deleted_by" CITEXT,
+    ADD COLUMN IF NOT EXISTS "deleted_at" TIMESTAMPTZ(3);
+
+ALTER TABLE threads
+    ADD COLUMN IF NOT EXISTS "deleted"    BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS "deleted_by" CITEXT,
+    ADD COLUMN IF NOT EXISTS "deleted_at" TIMESTAMPTZ(3);
+
+CREATE INDEX IF NOT EXISTS idx_threads_forum_created_visible ON threads (forum, created) WHERE NOT deleted;
+`
+	softDeleteDownSQL = `
+DROP INDEX IF EXISTS idx_threads_forum_created_visible;
+ALTER TABLE threads DROP COLUMN IF EXISTS "deleted", DROP COLUMN IF EXISTS "deleted_by", DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE posts DROP COLUMN IF EXISTS "deleted", DROP COLUMN IF EXISTS "deleted_by", DROP COLUMN IF EXISTS "deleted_at";
+`
+
+	// история правок постов: ревизия 1 - исходный текст, дальше по одной строке на каждую правку
+	postRevisionsUpSQL = `
+CREATE UNLOGGED TABLE IF NOT EXISTS post_revisions
+(
+    "post"     BIGINT  NOT NULL REFERENCES posts ("id") ON DELETE CASCADE,
+    "revision" INTEGER NOT NULL,
+    "message"  TEXT    NOT NULL,
+    "editor"   CITEXT  NOT NULL,
+    "created"  TIMESTAMPTZ(3) DEFAULT now(),
+    PRIMARY KEY ("post", "revision")
+);
+`
+	postRevisionsDownSQL = `
+DROP TABLE IF EXISTS post_revisions;
+`
+
+	// слаг треда теперь можно менять, уникальность держит база, а не проверка в Create.
+	// Треды без слага хранятся с пустой строкой, их много.
+	threadSlugUniqueUpSQL = `
+CREATE UNIQUE INDEX IF NOT EXISTS idx_threads_slug_unique ON threads (slug) WHERE slug <> '';
+`
+	threadSlugUniqueDownSQL = `
+DROP INDEX IF EXISTS idx_threads_slug_unique;
+`
+
+	// переименование форума: ссылки из threads и posts едут за слагом сами,
+	// forum_users без внешнего ключа и обновляется в репозитории
+	forumRenameArchiveUpSQL = `
+ALTER TABLE forums
+    ADD COLUMN IF NOT EXISTS "archived" BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE threads
+    DROP CONSTRAINT IF EXISTS threads_forum_fkey,
+    ADD CONSTRAINT threads_forum_fkey FOREIGN KEY ("forum") REFERENCES forums ("slug") ON UPDATE CASCADE;
+
+ALTER TABLE posts
+    DROP CONSTRAINT IF EXISTS posts_forum_fkey,
+    ADD CONSTRAINT posts_forum_fkey FOREIGN KEY ("forum") REFERENCES forums ("slug") ON UPDATE CASCADE;
+`
+	forumRenameArchiveDownSQL = `
+ALTER TABLE posts
+    DROP CONSTRAINT IF EXISTS posts_forum_fkey,
+    ADD CONSTRAINT posts_forum_fkey FOREIGN KEY ("forum") REFERENCES forums ("slug");
+
+ALTER TABLE threads
+    DROP CONSTRAINT IF EXISTS threads_forum_fkey,
+    ADD CONSTRAINT threads_forum_fkey FOREIGN KEY ("forum") REFERENCES forums ("slug");
+
+ALTER TABLE forums DROP COLUMN IF EXISTS "archived";
+`
+
+	// подфорумы: счётчики posts и threads обновляются у форума и всех его предков
+	subForumsUpSQL = `
+ALTER TABLE forums
+    ADD COLUMN IF NOT EXISTS "parent" CITEXT REFERENCES forums ("slug") ON UPDATE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_forums_parent ON forums (parent);
+
+CREATE OR REPLACE FUNCTION forum_lineage(CITEXT) RETURNS SETOF CITEXT AS
+$forum_lineage$
+WITH RECURSIVE lineage AS (
+    SELECT slug, parent
+    FROM forums
+    WHERE slug = $1
+    UNION ALL
+    SELECT f.slug, f.parent
+    FROM forums f
+             JOIN lineage l ON f.slug = l.parent
+)
+SELECT slug
+FROM lineage;
+$forum_lineage$
+    LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION thread_insert() RETURNS trigger AS
+$thread_insert$
+BEGIN
+    UPDATE forums
+    SET threads = threads + 1
+    WHERE slug IN (SELECT forum_lineage(NEW.forum));
+    RETURN NULL;
+END;
+$thread_insert$ LANGUAGE plpgsql;
+`
+	subForumsDownSQL = `
+CREATE OR REPLACE FUNCTION thread_insert() RETURNS trigger AS
+$thread_insert$
+BEGIN
+    UPDATE forums
+    SET threads = threads + 1
+    WHERE slug = NEW.forum;
+    RETURN NULL;
+END;
+$thread_insert$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS forum_lineage(CITEXT);
+DROP INDEX IF EXISTS idx_forums_parent;
+ALTER TABLE forums DROP COLUMN IF EXISTS "parent";
+`
+
+	// полнотекстовый поиск. Конфигурация simple: тексты на разных языках, без стемминга.
+	// Генерируемых колонок в 11 версии нет, search поддерживают триггеры.
+	searchUpSQL = `
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS "search" TSVECTOR;
+ALTER TABLE threads ADD COLUMN IF NOT EXISTS "search" TSVECTOR;
+
+UPDATE posts SET search = to_tsvector('pg_catalog.simple', message) WHERE search IS NULL;
+UPDATE threads SET search = to_tsvector('pg_catalog.simple', title || ' ' || message) WHERE search IS NULL;
+
+DROP TRIGGER IF EXISTS posts_search ON posts;
+CREATE TRIGGER posts_search
+    BEFORE INSERT OR UPDATE OF message
+    ON posts
+    FOR EACH ROW
+EXECUTE PROCEDURE tsvector_update_trigger(search, 'pg_catalog.simple', message);
+
+DROP TRIGGER IF EXISTS threads_search ON threads;
+CREATE TRIGGER threads_search
+    BEFORE INSERT OR UPDATE OF title, message
+    ON threads
+    FOR EACH ROW
+EXECUTE PROCEDURE tsvector_update_trigger(search, 'pg_catalog.simple', title, message);
+
+CREATE INDEX IF NOT EXISTS idx_posts_search ON posts USING GIN (search);
+CREATE INDEX IF NOT EXISTS idx_threads_search ON threads USING GIN (search);
+`
+	searchDownSQL = `
+DROP INDEX IF EXISTS idx_threads_search;
+DROP INDEX IF EXISTS idx_posts_search;
+DROP TRIGGER IF EXISTS threads_search ON threads;
+DROP TRIGGER IF EXISTS posts_search ON posts;
+ALTER TABLE threads DROP COLUMN IF EXISTS "search";
+ALTER TABLE posts DROP COLUMN IF EXISTS "search";
+`
+
+	// список пользователей в порядке forum_users (ucs_basic) и поиск по префиксу через LIKE
+	usersDirectoryUpSQL = `
+CREATE INDEX IF NOT EXISTS idx_users_nickname_ucs ON users (nickname COLLATE ucs_basic);
+CREATE INDEX IF NOT EXISTS idx_users_nickname_prefix ON users (lower(nickname::TEXT) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_users_fullname_prefix ON users (lower(fullname::TEXT) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_users_email_prefix ON users (lower(email::TEXT) text_pattern_ops);
+`
+	usersDirectoryDownSQL = `
+DROP INDEX IF EXISTS idx_users_nickname_ucs, idx_users_nickname_prefix, idx_users_fullname_prefix, idx_users_email_prefix;
+`
+
+	// посты и треды пользователя для страницы профиля
+	userActivityUpSQL = `
+CREATE INDEX IF NOT EXISTS idx_posts_author_id ON posts (author, id);
+CREATE INDEX IF NOT EXISTS idx_threads_author_created ON threads (author, created, id);
+`
+	userActivityDownSQL = `
+DROP INDEX IF EXISTS idx_posts_author_id, idx_threads_author_created;
+`
+
+	// bcrypt хэш пароля, у старых пользователей NULL и войти они не могут
+	userPasswordsUpSQL = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash TEXT;
+`
+	userPasswordsDownSQL = `
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
+`
+
+	// администраторы сервиса и модераторы форумов, владелец форума модератор и без записи здесь
+	rolesUpSQL = `
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS "is_admin" BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE UNLOGGED TABLE IF NOT EXISTS forum_moderators
+(
+    "forum"    CITEXT NOT NULL REFERENCES forums ("slug") ON UPDATE CASCADE ON DELETE CASCADE,
+    "nickname" CITEXT NOT NULL REFERENCES users ("nickname"),
+    PRIMARY KEY ("forum", "nickname")
+);
+`
+	rolesDownSQL = `
+DROP TABLE IF EXISTS forum_moderators;
+
+ALTER TABLE users DROP COLUMN IF EXISTS "is_admin";
+`
+
+	// отзыв голоса вычитает его из threads.votes, старые голоса с другим voice не проверяются
+	voteRetractUpSQL = `
+ALTER TABLE votes
+    DROP CONSTRAINT IF EXISTS votes_voice_check,
+    ADD CONSTRAINT votes_voice_check CHECK ("voice" IN (-1, 1)) NOT VALID;
+
+CREATE OR REPLACE FUNCTION delete_vote() RETURNS TRIGGER AS
+$delete_vote$
+BEGIN
+    UPDATE threads
+    SET votes = votes - OLD.voice
+    WHERE id = OLD.thread;
+    RETURN OLD;
+END;
+$delete_vote$
+    LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS delete_vote ON votes;
+CREATE TRIGGER delete_vote
+    AFTER DELETE
+    ON votes
+    FOR EACH ROW
+EXECUTE PROCEDURE delete_vote();
+`
+	voteRetractDownSQL = `
+DROP TRIGGER IF EXISTS delete_vote ON votes;
+DROP FUNCTION IF EXISTS delete_vote();
+
+ALTER TABLE votes DROP CONSTRAINT IF EXISTS votes_voice_check;
+`
+
+	// голоса за посты и карма авторов. Автор поста копируется в голос,
+	// чтобы при каскадном удалении поста триггер ещё знал, у кого вычесть карму.
+	// Карма за треды считается по голосам из votes, поэтому голоса треда удаляются раньше самого треда
+	postVotesUpSQL = `
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS "votes" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS "karma" INTEGER NOT NULL DEFAULT 0;
+
+CREATE UNLOGGED TABLE IF NOT EXISTS post_votes
+(
+    "post"     BIGINT  NOT NULL REFERENCES posts ("id") ON DELETE CASCADE,
+    "author"   CITEXT  NOT NULL,
+    "nickname" CITEXT  NOT NULL REFERENCES users ("nickname"),
+    "voice"    INTEGER NOT NULL CHECK ("voice" IN (-1, 1)),
+    PRIMARY KEY ("post", "nickname")
+);
+
+CREATE OR REPLACE FUNCTION post_vote() RETURNS TRIGGER AS
+$post_vote$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE posts SET votes = votes + NEW.voice WHERE id = NEW.post;
+        UPDATE users SET karma = karma + NEW.voice WHERE nickname = NEW.author;
+    ELSIF TG_OP = 'UPDATE' THEN
+        UPDATE posts SET votes = votes - OLD.voice + NEW.voice WHERE id = NEW.post;
+        UPDATE users SET karma = karma - OLD.voice + NEW.voice WHERE nickname = NEW.author;
+    ELSE
+        UPDATE posts SET votes = votes - OLD.voice WHERE id = OLD.post;
+        UPDATE users SET karma = karma - OLD.voice WHERE nickname = OLD.author;
+    END IF;
+    RETURN NULL;
+END;
+$post_vote$
+    LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS post_vote ON post_votes;
+CREATE TRIGGER post_vote
+    AFTER INSERT OR UPDATE OR DELETE
+    ON post_votes
+    FOR EACH ROW
+EXECUTE PROCEDURE post_vote();
+
+CREATE OR REPLACE FUNCTION thread_vote_karma() RETURNS TRIGGER AS
+$thread_vote_karma$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET karma = karma + NEW.voice
+        WHERE nickname = (SELECT author FROM threads WHERE id = NEW.thread);
+    ELSIF TG_OP = 'UPDATE' THEN
+        UPDATE users SET karma = karma - OLD.voice + NEW.voice
+        WHERE nickname = (SELECT author FROM threads WHERE id = NEW.thread);
+    ELSE
+        UPDATE users SET karma = karma - OLD.voice
+        WHERE nickname = (SELECT author FROM threads WHERE id = OLD.thread);
+    END IF;
+    RETURN NULL;
+END;
+$thread_vote_karma$
+    LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS thread_vote_karma ON votes;
+CREATE TRIGGER thread_vote_karma
+    AFTER INSERT OR UPDATE OR DELETE
+    ON votes
+    FOR EACH ROW
+EXECUTE PROCEDURE thread_vote_karma();
+
+UPDATE users u
+SET karma = t.votes
+FROM (SELECT author, sum(votes) AS votes FROM threads GROUP BY author) t
+WHERE u.nickname = t.author;
+`
+	postVotesDownSQL = `
+DROP TRIGGER IF EXISTS thread_vote_karma ON votes;
+DROP FUNCTION IF EXISTS thread_vote_karma();
+DROP TABLE IF EXISTS post_votes;
+DROP FUNCTION IF EXISTS post_vote();
+
+ALTER TABLE users DROP COLUMN IF EXISTS "karma";
+ALTER TABLE posts DROP COLUMN IF EXISTS "votes";
+`
+
+	// архивный форум только для чтения: проверка в самой вставке, чтобы не гоняться с архивацией.
+	// Код ошибки свой, см. models.PgxErrForumArchived
+	forumArchivedUpSQL = `
+CREATE OR REPLACE FUNCTION forum_archived() RETURNS TRIGGER AS
+$forum_archived$
+BEGIN
+    IF (SELECT archived FROM forums WHERE slug = NEW.forum) THEN
+        RAISE EXCEPTION 'forum % is archived', NEW.forum USING ERRCODE = 'DF001';
+    END IF;
+    RETURN NEW;
+END;
+$forum_archived$
+    LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS thread_forum_archived ON threads;
+CREATE TRIGGER thread_forum_archived
+    BEFORE INSERT
+    ON threads
+    FOR EACH ROW
+EXECUTE PROCEDURE forum_archived();
+
+DROP TRIGGER IF EXISTS post_forum_archived ON posts;
+CREATE TRIGGER post_forum_archived
+    BEFORE INSERT
+    ON posts
+    FOR EACH ROW
+EXECUTE PROCEDURE forum_archived();
+`
+	forumArchivedDownSQL = `
+DROP TRIGGER IF EXISTS post_forum_archived ON posts;
+DROP TRIGGER IF EXISTS thread_forum_archived ON threads;
+DROP FUNCTION IF EXISTS forum_archived();
+`
+
+	// forum_lineage не зацикливается на форуме, который ссылается сам на себя или на потомка
+	forumLineageGuardUpSQL = `
+CREATE OR REPLACE FUNCTION forum_lineage(CITEXT) RETURNS SETOF CITEXT AS
+$forum_lineage$
+WITH RECURSIVE lineage AS (
+    SELECT slug, parent, ARRAY[slug] AS path
+    FROM forums
+    WHERE slug = $1
+    UNION ALL
+    SELECT f.slug, f.parent, l.path || f.slug
+    FROM forums f
+             JOIN lineage l ON f.slug = l.parent
+    WHERE NOT f.slug = ANY(l.path)
+)
+SELECT slug
+FROM lineage;
+$forum_lineage$
+    LANGUAGE sql STABLE;
+`
+	forumLineageGuardDownSQL = `
+CREATE OR REPLACE FUNCTION forum_lineage(CITEXT) RETURNS SETOF CITEXT AS
+$forum_lineage$
+WITH RECURSIVE lineage AS (
+    SELECT slug, parent
+    FROM forums
+    WHERE slug = $1
+    UNION ALL
+    SELECT f.slug, f.parent
+    FROM forums f
+             JOIN lineage l ON f.slug = l.parent
+)
+SELECT slug
+FROM lineage;
+$forum_lineage$
+    LANGUAGE sql STABLE;
+`
+)
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+// migrationsLockID ключ advisory lock, чтобы два инстанса не катили миграции одновременно
+const migrationsLockID = 7204
+
+const (
+	createMigrationsTableSQL = `
+		CREATE TABLE IF NOT EXISTS schema_migrations
+		(
+			"version"    INTEGER PRIMARY KEY,
+			"name"       TEXT        NOT NULL,
+			"applied_at" TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`
+	getAppliedMigrationsSQL = `
+		SELECT version, applied_at
+		FROM schema_migrations
+		ORDER BY version
+	`
+	insertMigrationSQL = `
+		INSERT INTO schema_migrations (version, name)
+		VALUES ($1, $2)
+	`
+	deleteMigrationSQL = `
+		DELETE FROM schema_migrations
+		WHERE version = $1
+	`
+	lockMigrationsSQL   = `SELECT pg_advisory_xact_lock($1)`
+	getSchemaVersionSQL = `SELECT coalesce(max(version), 0) FROM schema_migrations`
+)
+
+// MigrationStatus состояние одной миграции в базе
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *pgx.ConnPool
+	migrations []Migration
+}
+
+func NewMigrator(db *pgx.ConnPool) *Migrator {
+	return &Migrator{db: db, migrations: Migrations()}
+}
+
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	if _, err := m.db.Exec(createMigrationsTableSQL); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(getAppliedMigrationsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err = rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// Status все миграции бинарника с отметкой о применении
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		at, ok := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{Migration: migration, Applied: ok, AppliedAt: at})
+	}
+	return statuses, nil
+}
+
+// Up применяет все ещё не применённые миграции, каждую в своей транзакции
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		ok, err := m.run(migration, migration.Up, insertMigrationSQL, migration.Version, migration.Name)
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %s", migration.Version, migration.Name, err)
+		}
+		if ok {
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// Down откатывает steps последних применённых миграций
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		ok, err := m.run(migration, migration.Down, deleteMigrationSQL, migration.Version)
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %s", migration.Version, migration.Name, err)
+		}
+		if ok {
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// run выполняет body и bookkeeping запрос в одной транзакции под advisory lock.
+// Возвращает false, если пока ждали lock, миграцию уже обработал другой инстанс.
+func (m *Migrator) run(migration Migration, body, bookkeeping string, args ...interface{}) (bool, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(lockMigrationsSQL, migrationsLockID); err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)`, migration.Version).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	if exists == (bookkeeping == insertMigrationSQL) {
+		return false, nil
+	}
+
+	if _, err = tx.Exec(body); err != nil {
+		return false, err
+	}
+	if _, err = tx.Exec(bookkeeping, args...); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
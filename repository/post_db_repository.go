@@ -1,8 +1,12 @@
 package repository
 
 import (
+	"context"
 	"fmt"
+	"github.com/AntonPriyma/db_forum/config"
+	"github.com/AntonPriyma/db_forum/metrics"
 	"github.com/AntonPriyma/db_forum/models"
+	"github.com/AntonPriyma/db_forum/utils"
 	"github.com/jackc/pgx"
 	"strconv"
 	"strings"
@@ -19,10 +23,21 @@ const (
 
 
 type PostRepository interface {
-	Create(posts *models.Posts, param string) (*models.Posts, error)
-	Update(postUpdate *models.PostUpdate, id int) (*models.Post, error)
-	GetPostByID(id int, related []string) (*models.PostFull, error)
-	GetThreadPostsDB(param, limit, since, sort, desc string) (*models.Posts, error)
+	Create(ctx context.Context, posts *models.Posts, param string) (*models.Posts, error)
+	Update(ctx context.Context, postUpdate *models.PostUpdate, id int) (*models.Post, error)
+	GetPostByID(ctx context.Context, id int, related []string) (*models.PostFull, error)
+	GetThreadPostsDB(ctx context.Context, param, limit, since, sort, desc string) (*models.Posts, error)
+	// Delete удаляет пост, с ответами поступает согласно children (config.DeleteReparent и т.д.)
+	Delete(ctx context.Context, id int, children string) (*models.Post, error)
+	// Hide скрывает пост от имени модератора nickname, в дереве остаётся заглушка. Restore возвращает текст
+	Hide(ctx context.Context, id int, nickname string) (*models.Post, error)
+	Restore(ctx context.Context, id int) (*models.Post, error)
+	// GetHistory все ревизии поста по возрастанию, у неотредактированного поста одна ревизия
+	GetHistory(ctx context.Context, id int) (*models.PostRevisions, error)
+	// GetUserPosts посты автора по id, since - id последнего поста предыдущей страницы, forum необязателен
+	GetUserPosts(ctx context.Context, nickname, limit, since, desc, forum string) (*models.Posts, error)
+	// VotePost голос за пост, повторный голос того же пользователя заменяет прежний, как у тредов
+	VotePost(ctx context.Context, id int, vote *models.Vote) (*models.Post, error)
 }
 
 type PostDBRepositoryImpl struct {
@@ -32,62 +47,56 @@ type PostDBRepositoryImpl struct {
 	db     *pgx.ConnPool
 }
 
-func(p *PostDBRepositoryImpl) checkPost(post *models.Post, t *models.Thread) error {
-	if p.users.authorExists(post.Author) {
+func(p *PostDBRepositoryImpl) checkPost(ctx context.Context, post *models.Post, t *models.Thread) error {
+	if p.users.authorExists(ctx, post.Author) {
 		return models.UserNotFound
 	}
-	if p.thread.parentExitsInOtherThread(post.Parent, t.ID) || p.thread.parentNotExists(post.Parent) {
+	if p.thread.parentExitsInOtherThread(ctx, post.Parent, t.ID) || p.thread.parentNotExists(ctx, post.Parent) {
 		return models.PostParentNotFound
 	}
 	return nil
 }
 
-func (p *PostDBRepositoryImpl) Create(posts *models.Posts, param string) (*models.Posts, error) {
-	thread, err := p.thread.GetThread(param)
+func (p *PostDBRepositoryImpl) Create(ctx context.Context, posts *models.Posts, param string) (*models.Posts, error) {
+	defer metrics.ObserveQuery("posts", "Create", "", time.Now())
+	thread, err := p.thread.GetThread(ctx, param)
 	if err != nil {
 		return nil, err
 	}
 
-	postsNumber := len(*posts)
-	if postsNumber == 0 {
+	if len(*posts) == 0 {
 		return posts, nil
 	}
 
-	dateTimeTemplate := "2006-01-02 15:04:05"
-	created := time.Now().Format(dateTimeTemplate)
-	query := strings.Builder{}
-	query.WriteString("INSERT INTO posts (author, created, message, thread, parent, forum, path) VALUES ")
-	queryBody := "('%s', '%s', '%s', %d, %d, '%s', (SELECT path FROM posts WHERE id = %d) || (SELECT last_value FROM posts_id_seq)),"
-	for i, post := range *posts {
-		err = p.checkPost(post, thread)
+	for _, post := range *posts {
+		err = p.checkPost(ctx, post, thread)
 		if err != nil {
 			return nil, err
 		}
-
-		temp := fmt.Sprintf(queryBody, post.Author, created, post.Message, thread.ID, post.Parent, thread.Forum, post.Parent)
-		// удаление запятой в конце queryBody для последнего подзапроса
-		if i == postsNumber-1 {
-			temp = temp[:len(temp)-1]
-		}
-		query.WriteString(temp)
 	}
-	query.WriteString("RETURNING author, created, forum, id, message, parent, thread")
 
-	tx, txErr := p.db.Begin()
+	query, args := buildInsertPostsQuery(*posts, thread, time.Now())
+
+	tx, txErr := p.db.BeginEx(ctx, nil)
 	if txErr != nil {
 		return nil, txErr
 	}
 	defer tx.Rollback()
 
-	rows, err := tx.Query(query.String())
-	defer rows.Close()
+	rows, err := tx.QueryEx(ctx, query, nil, args...)
 	if err != nil {
+		if ErrorCode(err) == models.PgxErrForumArchived {
+			return nil, models.ForumArchived
+		}
+		utils.Logger(ctx).WithError(err).WithField("thread", thread.ID).Error("posts insert failed")
 		return nil, err
 	}
+	defer rows.Close()
 	insertPosts := models.Posts{}
+	authors := make([]string, 0, len(*posts))
 	for rows.Next() {
 		post := models.Post{}
-		rows.Scan(
+		err = rows.Scan(
 			&post.Author,
 			&post.Created,
 			&post.Forum,
@@ -96,28 +105,82 @@ func (p *PostDBRepositoryImpl) Create(posts *models.Posts, param string) (*model
 			&post.Parent,
 			&post.Thread,
 		)
+		if err != nil {
+			return nil, err
+		}
 		insertPosts = append(insertPosts, &post)
+		authors = append(authors, post.Author)
 	}
+	rows.Close()
 	err = rows.Err()
 	if err != nil {
-		fmt.Println(err)
+		// ошибка триггера приходит вместе с первой строкой, а не из QueryEx
+		if ErrorCode(err) == models.PgxErrForumArchived {
+			return nil, models.ForumArchived
+		}
 		return nil, err
 	}
-	tx.Commit()
-	// по хорошему это впихнуть в хранимые процедуры, но нормальные ребята предпочитают костылить
-	p.db.Exec(`UPDATE forums SET posts = posts + $1 WHERE slug = $2`, len(insertPosts), thread.Forum)
 
-	for _, post := range insertPosts {
-		p.db.Exec(`  INSERT INTO forum_users ("forum_user", "forum", "email", "fullname", "about")
-		SELECT nickname, $2, email, fullname, about
-		FROM users
-		WHERE nickname = $1
-		ON CONFLICT DO NOTHING;`, post.Author, post.Forum)
+	// счётчик форума и forum_users обновляются в той же транзакции, что и сами посты,
+	// иначе при падении между коммитом и апдейтами они разъезжаются
+	_, err = tx.ExecEx(ctx, updateForumPostsCountSQL, nil, len(insertPosts), thread.Forum)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecEx(ctx, addForumUsersSQL, nil, authors, thread.Forum)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
 	}
 
 	return &insertPosts, nil
 }
 
+// buildInsertPostsQuery собирает один INSERT на всю пачку постов.
+// Общие для пачки created, thread и forum идут параметрами $1-$3,
+// на каждый пост добавляется ещё три: author, message и parent.
+// Всё пользовательское уходит только через bind параметры.
+func buildInsertPostsQuery(posts models.Posts, thread *models.Thread, created time.Time) (string, []interface{}) {
+	query := strings.Builder{}
+	query.WriteString("INSERT INTO posts (author, created, message, thread, parent, forum, path) VALUES ")
+
+	args := make([]interface{}, 0, 3+3*len(posts))
+	args = append(args, created, thread.ID, thread.Forum)
+	for i, post := range posts {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&query,
+			"($%d, $1, $%d, $2, $%d::BIGINT, $3, (SELECT path FROM posts WHERE id = $%d::BIGINT) || (SELECT last_value FROM posts_id_seq))",
+			n+1, n+2, n+3, n+3,
+		)
+		args = append(args, post.Author, post.Message, post.Parent)
+	}
+	query.WriteString(" RETURNING author, created, forum, id, message, parent, thread")
+
+	return query.String(), args
+}
+
+// sortVariant метка варианта запроса для метрик, например tree_desc_since
+func sortVariant(sort, desc, since string) string {
+	variant := sort
+	if desc == "true" {
+		variant += "_desc"
+	}
+	if since != "" {
+		variant += "_since"
+	}
+	if variant == "" {
+		return "asc"
+	}
+	return strings.TrimPrefix(variant, "_")
+}
+
 var queryPostsWithSience = map[string]map[string]string{
 	"true": map[string]string{
 		"tree":        getPostsSienceDescLimitTreeSQL,
@@ -144,17 +207,50 @@ var queryPostsNoSience = map[string]map[string]string{
 	},
 }
 
-func (p *PostDBRepositoryImpl) Update(postUpdate *models.PostUpdate, id int) (*models.Post, error) {
-	post, err := p.GetPostDB(id)
+func (p *PostDBRepositoryImpl) Update(ctx context.Context, postUpdate *models.PostUpdate, id int) (*models.Post, error) {
+	defer metrics.ObserveQuery("posts", "Update", "", time.Now())
+	post, err := p.GetPostDB(ctx, id)
 	if err != nil {
 		return nil, models.PostNotFound
 	}
 
 	if len(postUpdate.Message) == 0 {
+		post.HideDeleted()
 		return post, nil
 	}
 
-	rows := p.db.QueryRow(updatePostSQL, strconv.Itoa(id), &postUpdate.Message)
+	editor := post.Author
+	if postUpdate.Editor != "" {
+		user, err := p.users.GetUserByNickname(ctx, postUpdate.Editor)
+		if err != nil {
+			return nil, err
+		}
+		editor = user.Nickname
+	}
+
+	tx, err := p.db.BeginEx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// текст до правки берём под блокировкой, чтобы параллельные правки не потеряли ревизию
+	var author, message string
+	var created time.Time
+	err = tx.QueryRowEx(ctx, getPostForUpdateSQL, nil, id).Scan(&author, &message, &created)
+	if err != nil {
+		return nil, models.PostNotFound
+	}
+	if message != postUpdate.Message {
+		if _, err = tx.ExecEx(ctx, addPostOriginalRevisionSQL, nil, id, message, author, created); err != nil {
+			return nil, err
+		}
+		if _, err = tx.ExecEx(ctx, addPostRevisionSQL, nil, id, postUpdate.Message, editor); err != nil {
+			return nil, err
+		}
+	}
+
+	rows := tx.QueryRowEx(ctx, updatePostSQL, nil, strconv.Itoa(id), &postUpdate.Message)
 
 	err = rows.Scan(
 		&post.Author,
@@ -164,13 +260,22 @@ func (p *PostDBRepositoryImpl) Update(postUpdate *models.PostUpdate, id int) (*m
 		&post.Thread,
 		&post.Message,
 		&post.Parent,
+		&post.Votes,
+		&post.Deleted,
+		&post.DeletedBy,
+		&post.DeletedAt,
 	)
 
 	if err == nil {
+		err = tx.Commit()
+	}
+	if err == nil {
+		post.HideDeleted()
 		return post, nil
 	} else if (err.Error() == noRowsInResult) {
 		return nil, models.PostNotFound
 	} else {
+		utils.Logger(ctx).WithError(err).WithField("post", id).Error("post update failed")
 		return nil, err
 	}
 }
@@ -178,11 +283,12 @@ func (p *PostDBRepositoryImpl) Update(postUpdate *models.PostUpdate, id int) (*m
 const noRowsInResult 		= "no rows in result set"
 
 
-func(p *PostDBRepositoryImpl) GetPostDB(id int) (*models.Post, error) {
+func(p *PostDBRepositoryImpl) GetPostDB(ctx context.Context, id int) (*models.Post, error) {
+	defer metrics.ObserveQuery("posts", "GetPostDB", "", time.Now())
 	post := models.Post{}
 
-	err := p.db.QueryRow(
-		getPostSQL,
+	err := p.db.QueryRowEx(ctx,
+		getPostSQL, nil,
 		id,
 	).Scan(
 		&post.ID,
@@ -193,6 +299,10 @@ func(p *PostDBRepositoryImpl) GetPostDB(id int) (*models.Post, error) {
 		&post.Created,
 		&post.IsEdited,
 		&post.Parent,
+		&post.Votes,
+		&post.Deleted,
+		&post.DeletedBy,
+		&post.DeletedAt,
 	)
 
 	if err == nil {
@@ -204,22 +314,24 @@ func(p *PostDBRepositoryImpl) GetPostDB(id int) (*models.Post, error) {
 	}
 }
 
-func (p *PostDBRepositoryImpl) GetPostByID(id int, related []string) (*models.PostFull, error) {
+func (p *PostDBRepositoryImpl) GetPostByID(ctx context.Context, id int, related []string) (*models.PostFull, error) {
+	defer metrics.ObserveQuery("posts", "GetPostByID", "", time.Now())
 	postFull := models.PostFull{}
 	var err error
-	postFull.Post, err = p.GetPostDB(id)
+	postFull.Post, err = p.GetPostDB(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	postFull.Post.HideDeleted()
 
 	for _, model := range related {
 		switch model {
 		case "thread":
-			postFull.Thread, err = p.thread.GetThread(strconv.Itoa(int(postFull.Post.Thread)))
+			postFull.Thread, err = p.thread.GetThread(ctx, strconv.Itoa(int(postFull.Post.Thread)))
 		case "forum":
-			postFull.Forum, err = p.forum.GetForumBySlug(postFull.Post.Forum)
+			postFull.Forum, err = p.forum.GetForumBySlug(ctx, postFull.Post.Forum)
 		case "user":
-			postFull.Author, err = p.users.GetUserByNickname(postFull.Post.Author)
+			postFull.Author, err = p.users.GetUserByNickname(ctx, postFull.Post.Author)
 		}
 
 		if err != nil {
@@ -230,8 +342,9 @@ func (p *PostDBRepositoryImpl) GetPostByID(id int, related []string) (*models.Po
 	return &postFull, nil
 }
 
-func (p *PostDBRepositoryImpl) GetThreadPostsDB(param, limit, since, sort, desc string) (*models.Posts, error) {
-	thread, err := p.thread.GetThread(param)
+func (p *PostDBRepositoryImpl) GetThreadPostsDB(ctx context.Context, param, limit, since, sort, desc string) (*models.Posts, error) {
+	defer metrics.ObserveQuery("posts", "GetThreadPostsDB", sortVariant(sort, desc, since), time.Now())
+	thread, err := p.thread.GetThread(ctx, param)
 	if err != nil {
 		return nil, models.ForumNotFound
 	}
@@ -240,14 +353,15 @@ func (p *PostDBRepositoryImpl) GetThreadPostsDB(param, limit, since, sort, desc
 
 	if since != "" {
 		query := queryPostsWithSience[desc][sort]
-		rows, err = p.db.Query(query, thread.ID, since, limit)
+		rows, err = p.db.QueryEx(ctx, query, nil, thread.ID, since, limit)
 	} else {
 		query := queryPostsNoSience[desc][sort]
-		rows, err = p.db.Query(query, thread.ID, limit)
+		rows, err = p.db.QueryEx(ctx, query, nil, thread.ID, limit)
 	}
 	defer rows.Close()
 
 	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("variant", sortVariant(sort, desc, since)).Error("thread posts query failed")
 		return nil, err
 	}
 
@@ -263,10 +377,15 @@ func (p *PostDBRepositoryImpl) GetThreadPostsDB(param, limit, since, sort, desc
 			&post.Forum,
 			&post.Thread,
 			&post.Created,
+			&post.Votes,
+			&post.Deleted,
+			&post.DeletedBy,
+			&post.DeletedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
+		post.HideDeleted()
 		posts = append(posts, &post)
 	}
 	err = rows.Err()
@@ -276,6 +395,263 @@ func (p *PostDBRepositoryImpl) GetThreadPostsDB(param, limit, since, sort, desc
 	return &posts, nil
 }
 
+// Delete удаляет пост в одной транзакции вместе с правкой счётчика форума.
+// Возвращает пост в том виде, в каком он был до удаления, или заглушку для tombstone.
+func (p *PostDBRepositoryImpl) Delete(ctx context.Context, id int, children string) (*models.Post, error) {
+	defer metrics.ObserveQuery("posts", "Delete", children, time.Now())
+
+	tx, err := p.db.BeginEx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	post := models.Post{}
+	var path []int64
+	err = tx.QueryRowEx(ctx, getPostForDeleteSQL, nil, id).Scan(
+		&post.ID,
+		&post.Author,
+		&post.Message,
+		&post.Forum,
+		&post.Thread,
+		&post.Created,
+		&post.IsEdited,
+		&post.Parent,
+		&path,
+		&post.Votes,
+		&post.Deleted,
+		&post.DeletedBy,
+		&post.DeletedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, models.PostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var removed int64
+	switch children {
+	case config.DeleteCascade:
+		tag, err := tx.ExecEx(ctx, deletePostSubtreeSQL, nil, post.Thread, path, len(path))
+		if err != nil {
+			return nil, err
+		}
+		removed = tag.RowsAffected()
+	case config.DeleteTombstone:
+		var hasReplies bool
+		err = tx.QueryRowEx(ctx, hasPostRepliesSQL, nil, post.Thread, path, len(path)).Scan(&hasReplies)
+		if err != nil {
+			return nil, err
+		}
+		if hasReplies {
+			post.Deleted = true
+			if err = tx.QueryRowEx(ctx, tombstonePostSQL, nil, post.ID).Scan(&post.DeletedAt); err != nil {
+				return nil, err
+			}
+		} else {
+			if _, err = tx.ExecEx(ctx, deletePostSQL, nil, post.ID); err != nil {
+				return nil, err
+			}
+			removed = 1
+		}
+	default:
+		// ответы переезжают к родителю, из их path выбрасывается id удалённого поста
+		_, err = tx.ExecEx(ctx, reparentPostRepliesSQL, nil, post.Thread, path, len(path), post.ID, post.Parent)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = tx.ExecEx(ctx, deletePostSQL, nil, post.ID); err != nil {
+			return nil, err
+		}
+		removed = 1
+	}
+
+	if _, err = tx.ExecEx(ctx, updateForumPostsCountSQL, nil, -removed, post.Forum); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		utils.Logger(ctx).WithError(err).WithField("post", id).Error("post delete failed")
+		return nil, err
+	}
+
+	post.HideDeleted()
+	return &post, nil
+}
+
+func (p *PostDBRepositoryImpl) Hide(ctx context.Context, id int, nickname string) (*models.Post, error) {
+	defer metrics.ObserveQuery("posts", "Hide", "", time.Now())
+	moderator, err := p.users.GetUserByNickname(ctx, nickname)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.setDeleted(ctx, hidePostSQL, id, moderator.Nickname)
+}
+
+func (p *PostDBRepositoryImpl) Restore(ctx context.Context, id int) (*models.Post, error) {
+	defer metrics.ObserveQuery("posts", "Restore", "", time.Now())
+	return p.setDeleted(ctx, restorePostSQL, id)
+}
+
+// setDeleted выполняет hidePostSQL или restorePostSQL и читает пост из RETURNING
+func (p *PostDBRepositoryImpl) setDeleted(ctx context.Context, query string, id int, args ...interface{}) (*models.Post, error) {
+	post := models.Post{}
+	err := p.db.QueryRowEx(ctx, query, nil, append([]interface{}{id}, args...)...).Scan(
+		&post.ID,
+		&post.Author,
+		&post.Message,
+		&post.Forum,
+		&post.Thread,
+		&post.Created,
+		&post.IsEdited,
+		&post.Parent,
+		&post.Votes,
+		&post.Deleted,
+		&post.DeletedBy,
+		&post.DeletedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, models.PostNotFound
+	}
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("post", id).Error("post visibility update failed")
+		return nil, err
+	}
+
+	post.HideDeleted()
+	return &post, nil
+}
+
+func (p *PostDBRepositoryImpl) GetHistory(ctx context.Context, id int) (*models.PostRevisions, error) {
+	defer metrics.ObserveQuery("posts", "GetHistory", "", time.Now())
+	post, err := p.GetPostDB(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.db.QueryEx(ctx, getPostRevisionsSQL, nil, id)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("post", id).Error("post history query failed")
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := models.PostRevisions{}
+	for rows.Next() {
+		revision := models.PostRevision{}
+		err = rows.Scan(
+			&revision.Revision,
+			&revision.Message,
+			&revision.Editor,
+			&revision.Created,
+		)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// пост ни разу не правили, ревизии ещё не заведены
+	if len(revisions) == 0 {
+		revisions = append(revisions, &models.PostRevision{
+			Revision: 1,
+			Message:  post.Message,
+			Editor:   post.Author,
+			Created:  post.Created,
+		})
+	}
+	hideDeletedRevisions(post, revisions)
+
+	return &revisions, nil
+}
+
+// hideDeletedRevisions у скрытого поста прячется и история
+func hideDeletedRevisions(post *models.Post, revisions models.PostRevisions) {
+	if !post.Deleted {
+		return
+	}
+	for _, revision := range revisions {
+		revision.Message = models.DeletedMessage
+	}
+}
+
 func NewPostDBRepositoryImpl(users UsersRepository, thread ThreadDBRepository,forum ForumRepository, db *pgx.ConnPool) PostRepository {
 	return &PostDBRepositoryImpl{users: users, thread: thread,forum:forum, db: db}
 }
+
+var queryUserPosts = map[string]string{
+	"true":  getUserPostsDescSQL,
+	"false": getUserPostsSQL,
+}
+
+func (p *PostDBRepositoryImpl) GetUserPosts(ctx context.Context, nickname, limit, since, desc, forum string) (*models.Posts, error) {
+	defer metrics.ObserveQuery("posts", "GetUserPosts", sortVariant("", desc, since), time.Now())
+	rows, err := p.db.QueryEx(ctx, queryUserPosts[desc], nil, nickname, limit, since, forum)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("nickname", nickname).Error("user posts query failed")
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := models.Posts{}
+	for rows.Next() {
+		post := models.Post{}
+		err = rows.Scan(
+			&post.ID,
+			&post.Author,
+			&post.Parent,
+			&post.Message,
+			&post.Forum,
+			&post.Thread,
+			&post.Created,
+			&post.IsEdited,
+			&post.Votes,
+			&post.Deleted,
+			&post.DeletedBy,
+			&post.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, &post)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(posts) == 0 {
+		if _, err := p.users.GetUserByNickname(ctx, nickname); err != nil {
+			return nil, models.UserNotFound
+		}
+	}
+	return &posts, nil
+}
+
+func (p *PostDBRepositoryImpl) VotePost(ctx context.Context, id int, vote *models.Vote) (*models.Post, error) {
+	defer metrics.ObserveQuery("posts", "VotePost", "", time.Now())
+	tag, err := p.db.ExecEx(ctx, votePostSQL, nil, id, vote.Nickname, vote.Voice)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("post", id).Error("post vote failed")
+		return nil, err
+	}
+
+	// votes уже пересчитан триггером post_vote
+	post, err := p.GetPostDB(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, models.UserNotFound
+	}
+
+	voice := int32(vote.Voice)
+	post.Voice = &voice
+	post.HideDeleted()
+	return post, nil
+}
+
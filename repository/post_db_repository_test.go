@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AntonPriyma/db_forum/config"
+	"github.com/AntonPriyma/db_forum/models"
+)
+
+var trickyMessages = []string{
+	`it's a "quoted" message`,
+	`'); DROP TABLE posts; --`,
+	`back\slash \' \\ \n`,
+	"юникод, emoji 🐘 и 中文",
+	"$1 $2 %s %d",
+}
+
+func TestBuildInsertPostsQueryUsesBindParameters(t *testing.T) {
+	thread := &models.Thread{ID: 42, Forum: "o'forum"}
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	posts := models.Posts{}
+	for i, message := range trickyMessages {
+		posts = append(posts, &models.Post{Author: "it's.me", Message: message, Parent: int64(i)})
+	}
+
+	query, args := buildInsertPostsQuery(posts, thread, created)
+
+	if want := 3 + 3*len(posts); len(args) != want {
+		t.Fatalf("got %d args, want %d", len(args), want)
+	}
+	if args[0] != created || args[1] != thread.ID || args[2] != thread.Forum {
+		t.Errorf("shared args = %v, want created, thread id and forum", args[:3])
+	}
+	for i, message := range trickyMessages {
+		if got := args[3+3*i+1]; got != message {
+			t.Errorf("message arg %d = %q, want %q", i, got, message)
+		}
+		if got := args[3+3*i]; got != "it's.me" {
+			t.Errorf("author arg %d = %q", i, got)
+		}
+		if got := args[3+3*i+2]; got != int64(i) {
+			t.Errorf("parent arg %d = %v, want %d", i, got, i)
+		}
+	}
+
+	if strings.ContainsAny(query, `'\`) {
+		t.Errorf("query contains literal values: %s", query)
+	}
+	if want := fmt.Sprintf("$%d", len(args)); !strings.Contains(query, want) {
+		t.Errorf("query does not reference last parameter %s: %s", want, query)
+	}
+	if strings.Contains(query, fmt.Sprintf("$%d", len(args)+1)) {
+		t.Errorf("query references parameter beyond args: %s", query)
+	}
+}
+
+// testDB поднимает подключение к postgres из переменных DB_FORUM_*
+// и накатывает миграции. Тест пропускается, если DB_FORUM_TEST_POSTGRES не задана.
+func testDB(t *testing.T) {
+	t.Helper()
+	if os.Getenv("DB_FORUM_TEST_POSTGRES") == "" {
+		t.Skip("DB_FORUM_TEST_POSTGRES is not set")
+	}
+
+	cfg, _, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if GetDB() == nil {
+		if err := ConnetctDB(NewDBService(), cfg.Database); err != nil {
+			t.Fatal(err.Message)
+		}
+	}
+	if _, err := NewMigrator(GetDB()).Up(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostCreateRoundTripsMessages(t *testing.T) {
+	testDB(t)
+
+	ctx := context.Background()
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	forums := NewForumRepositoryImpl(GetDB())
+	users := NewUsersRepositoryImpl(GetDB(), forums)
+	threads := NewThreadDBRepositoryImpl(GetDB(), forums)
+	posts := NewPostDBRepositoryImpl(users, threads, forums, GetDB())
+
+	author := &models.User{Nickname: "u" + suffix, Fullname: "Round Trip", Email: suffix + "@example.com"}
+	if _, err := users.Create(ctx, author); err != nil {
+		t.Fatal(err)
+	}
+	forum, err := forums.Create(ctx, &models.Forum{Slug: "f" + suffix, Title: "round trip", Owner: author.Nickname})
+	if err != nil {
+		t.Fatal(err)
+	}
+	thread, err := threads.Create(ctx, &models.Thread{Author: author.Nickname, Forum: forum.Slug, Title: "t", Message: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := models.Posts{}
+	for _, message := range trickyMessages {
+		batch = append(batch, &models.Post{Author: author.Nickname, Message: message})
+	}
+	created, err := posts.Create(ctx, &batch, fmt.Sprintf("%d", thread.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*created) != len(trickyMessages) {
+		t.Fatalf("created %d posts, want %d", len(*created), len(trickyMessages))
+	}
+
+	for i, post := range *created {
+		if !post.Created.Equal((*created)[0].Created) {
+			t.Errorf("post %d created %v, want shared %v", post.ID, post.Created, (*created)[0].Created)
+		}
+		full, err := posts.GetPostByID(ctx, int(post.ID), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if full.Post.Message != trickyMessages[i] {
+			t.Errorf("post %d message = %q, want %q", post.ID, full.Post.Message, trickyMessages[i])
+		}
+	}
+
+	forum, err = forums.GetForumBySlug(ctx, forum.Slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forum.Posts != int64(len(trickyMessages)) {
+		t.Errorf("forum posts = %d, want %d", forum.Posts, len(trickyMessages))
+	}
+	members, err := forums.GetForumUsersDB(ctx, forum.Slug, "10", "", "false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*members) != 1 || (*members)[0].Nickname != author.Nickname {
+		t.Errorf("forum users = %v, want only %s", *members, author.Nickname)
+	}
+}
@@ -0,0 +1,516 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/AntonPriyma/db_forum/config"
+	"github.com/AntonPriyma/db_forum/models"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type PostMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewPostMemoryRepository(store *MemoryStore) PostRepository {
+	return &PostMemoryRepository{store: store}
+}
+
+func (p *PostMemoryRepository) Create(ctx context.Context, posts *models.Posts, param string) (*models.Posts, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	thread, ok := p.store.threadByParam(param)
+	if !ok {
+		return nil, models.ThreadNotFound
+	}
+	if len(*posts) == 0 {
+		return posts, nil
+	}
+
+	for _, post := range *posts {
+		if _, ok := p.store.users[key(post.Author)]; !ok {
+			return nil, models.UserNotFound
+		}
+		if post.Parent != 0 {
+			parent, ok := p.store.posts[post.Parent]
+			if !ok || parent.Thread != thread.ID {
+				return nil, models.PostParentNotFound
+			}
+		}
+	}
+	// триггер forum_archived
+	if forum, ok := p.store.forums[key(thread.Forum)]; ok && forum.Archived {
+		return nil, models.ForumArchived
+	}
+
+	// created с точностью TIMESTAMPTZ(3)
+	created := time.Now().Truncate(time.Millisecond)
+	insertPosts := models.Posts{}
+	for _, post := range *posts {
+		p.store.lastPostID++
+		stored := &memoryPost{Post: models.Post{
+			Author:  post.Author,
+			Created: created,
+			Forum:   thread.Forum,
+			ID:      p.store.lastPostID,
+			Message: post.Message,
+			Parent:  post.Parent,
+			Thread:  thread.ID,
+		}}
+		if post.Parent != 0 {
+			stored.path = append(stored.path, p.store.posts[post.Parent].path...)
+		}
+		stored.path = append(stored.path, stored.ID)
+
+		p.store.posts[stored.ID] = stored
+		p.store.threadPosts[thread.ID] = append(p.store.threadPosts[thread.ID], stored)
+		p.store.addForumUser(thread.Forum, post.Author)
+
+		result := stored.Post
+		insertPosts = append(insertPosts, &result)
+	}
+	p.store.addForumCounters(thread.Forum, 0, int64(len(insertPosts)))
+
+	return &insertPosts, nil
+}
+
+func (p *PostMemoryRepository) Update(ctx context.Context, postUpdate *models.PostUpdate, id int) (*models.Post, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	stored, ok := p.store.posts[int64(id)]
+	if !ok {
+		return nil, models.PostNotFound
+	}
+	if len(postUpdate.Message) != 0 {
+		editor := stored.Author
+		if postUpdate.Editor != "" {
+			user, ok := p.store.users[key(postUpdate.Editor)]
+			if !ok {
+				return nil, models.UserNotFound
+			}
+			editor = user.Nickname
+		}
+		if postUpdate.Message != stored.Message {
+			if len(stored.revisions) == 0 {
+				stored.revisions = append(stored.revisions, &models.PostRevision{
+					Revision: 1,
+					Message:  stored.Message,
+					Editor:   stored.Author,
+					Created:  stored.Created,
+				})
+			}
+			stored.revisions = append(stored.revisions, &models.PostRevision{
+				Revision: int32(len(stored.revisions) + 1),
+				Message:  postUpdate.Message,
+				Editor:   editor,
+				Created:  time.Now().Truncate(time.Millisecond),
+			})
+		}
+		stored.IsEdited = postUpdate.Message != stored.Message
+		stored.Message = postUpdate.Message
+	}
+
+	post := stored.Post
+	post.HideDeleted()
+	return &post, nil
+}
+
+func (p *PostMemoryRepository) GetPostByID(ctx context.Context, id int, related []string) (*models.PostFull, error) {
+	p.store.mu.RLock()
+	defer p.store.mu.RUnlock()
+
+	stored, ok := p.store.posts[int64(id)]
+	if !ok {
+		return nil, models.PostNotFound
+	}
+	post := stored.Post
+	post.HideDeleted()
+	postFull := models.PostFull{Post: &post}
+
+	for _, model := range related {
+		switch model {
+		case "thread":
+			thread, ok := p.store.threads[post.Thread]
+			if !ok {
+				return nil, models.ThreadNotFound
+			}
+			copied := *thread
+			copied.HideDeleted()
+			postFull.Thread = &copied
+		case "forum":
+			forum, ok := p.store.forums[key(post.Forum)]
+			if !ok {
+				return nil, models.ForumNotFound
+			}
+			copied := *forum
+			postFull.Forum = &copied
+		case "user":
+			user, ok := p.store.users[key(post.Author)]
+			if !ok {
+				return nil, models.UserNotFound
+			}
+			copied := *user
+			postFull.Author = &copied
+		}
+	}
+
+	return &postFull, nil
+}
+
+func (p *PostMemoryRepository) GetThreadPostsDB(ctx context.Context, param, limit, since, sortMode, desc string) (*models.Posts, error) {
+	p.store.mu.RLock()
+	defer p.store.mu.RUnlock()
+
+	thread, ok := p.store.threadByParam(param)
+	if !ok {
+		return nil, models.ForumNotFound
+	}
+
+	n, err := parseLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	if desc != "true" && desc != "false" {
+		return nil, fmt.Errorf("unknown desc %q", desc)
+	}
+	isDesc := desc == "true"
+
+	var sincePost *memoryPost
+	if since != "" {
+		sinceID, err := strconv.ParseInt(since, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		// в postgres сравнение с path несуществующего поста даёт NULL и пустой результат
+		if sincePost, ok = p.store.posts[sinceID]; !ok && sortMode != "flat" {
+			return &models.Posts{}, nil
+		}
+	}
+
+	all := p.store.threadPosts[thread.ID]
+	var selected []*memoryPost
+	switch sortMode {
+	case "flat":
+		selected = flatPosts(all, since, isDesc)
+	case "tree":
+		selected = treePosts(all, sincePost, isDesc)
+	case "parent_tree":
+		selected = parentTreePosts(all, sincePost, isDesc, n)
+	default:
+		return nil, fmt.Errorf("unknown sort %q", sortMode)
+	}
+	if sortMode != "parent_tree" && len(selected) > n {
+		selected = selected[:n]
+	}
+
+	posts := models.Posts{}
+	for _, stored := range selected {
+		post := stored.Post
+		post.HideDeleted()
+		posts = append(posts, &post)
+	}
+	return &posts, nil
+}
+
+func flatPosts(all []*memoryPost, since string, desc bool) []*memoryPost {
+	sinceID, _ := strconv.ParseInt(since, 10, 64)
+	selected := make([]*memoryPost, 0, len(all))
+	for _, post := range all {
+		if post.Deleted || (since != "" && ((desc && post.ID >= sinceID) || (!desc && post.ID <= sinceID))) {
+			continue
+		}
+		selected = append(selected, post)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if desc {
+			return selected[i].ID > selected[j].ID
+		}
+		return selected[i].ID < selected[j].ID
+	})
+	return selected
+}
+
+func treePosts(all []*memoryPost, since *memoryPost, desc bool) []*memoryPost {
+	selected := make([]*memoryPost, 0, len(all))
+	for _, post := range all {
+		if since != nil {
+			cmp := comparePaths(post.path, since.path)
+			if (desc && cmp >= 0) || (!desc && cmp <= 0) {
+				continue
+			}
+		}
+		selected = append(selected, post)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		cmp := comparePaths(selected[i].path, selected[j].path)
+		if desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+	return selected
+}
+
+// parentTreePosts limit считается по корневым постам, внутри ветки порядок по path
+func parentTreePosts(all []*memoryPost, since *memoryPost, desc bool, limit int) []*memoryPost {
+	roots := make([]int64, 0)
+	for _, post := range all {
+		if post.Parent != 0 {
+			continue
+		}
+		if since != nil && ((desc && post.path[0] >= since.path[0]) || (!desc && post.path[0] <= since.path[0])) {
+			continue
+		}
+		roots = append(roots, post.path[0])
+	}
+	sort.Slice(roots, func(i, j int) bool {
+		if desc {
+			return roots[i] > roots[j]
+		}
+		return roots[i] < roots[j]
+	})
+	if len(roots) > limit {
+		roots = roots[:limit]
+	}
+
+	chosen := make(map[int64]bool, len(roots))
+	for _, root := range roots {
+		chosen[root] = true
+	}
+	selected := make([]*memoryPost, 0)
+	for _, post := range all {
+		if chosen[post.path[0]] {
+			selected = append(selected, post)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		a, b := selected[i].path, selected[j].path
+		if desc && a[0] != b[0] {
+			return a[0] > b[0]
+		}
+		return comparePaths(a, b) < 0
+	})
+	return selected
+}
+
+func (p *PostMemoryRepository) Delete(ctx context.Context, id int, children string) (*models.Post, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	stored, ok := p.store.posts[int64(id)]
+	if !ok {
+		return nil, models.PostNotFound
+	}
+	deleted := stored.Post
+
+	replies := make([]*memoryPost, 0)
+	for _, post := range p.store.threadPosts[stored.Thread] {
+		if post.ID != stored.ID && hasPathPrefix(post.path, stored.path) {
+			replies = append(replies, post)
+		}
+	}
+
+	removed := map[int64]bool{stored.ID: true}
+	switch children {
+	case config.DeleteCascade:
+		for _, reply := range replies {
+			removed[reply.ID] = true
+		}
+	case config.DeleteTombstone:
+		if len(replies) != 0 {
+			now := time.Now().Truncate(time.Millisecond)
+			stored.Deleted = true
+			stored.DeletedAt = &now
+			deleted = stored.Post
+			delete(removed, stored.ID)
+		}
+	default:
+		for _, reply := range replies {
+			if reply.Parent == stored.ID {
+				reply.Parent = stored.Parent
+			}
+			path := make([]int64, 0, len(reply.path)-1)
+			for _, postID := range reply.path {
+				if postID != stored.ID {
+					path = append(path, postID)
+				}
+			}
+			reply.path = path
+		}
+	}
+
+	if len(removed) != 0 {
+		kept := make([]*memoryPost, 0, len(p.store.threadPosts[stored.Thread]))
+		for _, post := range p.store.threadPosts[stored.Thread] {
+			if removed[post.ID] {
+				p.store.removePostVotes(post)
+				delete(p.store.posts, post.ID)
+				continue
+			}
+			kept = append(kept, post)
+		}
+		p.store.threadPosts[stored.Thread] = kept
+	}
+	p.store.addForumCounters(stored.Forum, 0, -int64(len(removed)))
+
+	deleted.HideDeleted()
+	return &deleted, nil
+}
+
+func (p *PostMemoryRepository) Hide(ctx context.Context, id int, nickname string) (*models.Post, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	moderator, ok := p.store.users[key(nickname)]
+	if !ok {
+		return nil, models.UserNotFound
+	}
+	stored, ok := p.store.posts[int64(id)]
+	if !ok {
+		return nil, models.PostNotFound
+	}
+
+	now := time.Now().Truncate(time.Millisecond)
+	stored.Deleted = true
+	stored.DeletedBy = moderator.Nickname
+	stored.DeletedAt = &now
+
+	post := stored.Post
+	post.HideDeleted()
+	return &post, nil
+}
+
+func (p *PostMemoryRepository) Restore(ctx context.Context, id int) (*models.Post, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	stored, ok := p.store.posts[int64(id)]
+	if !ok {
+		return nil, models.PostNotFound
+	}
+	stored.Deleted = false
+	stored.DeletedBy = ""
+	stored.DeletedAt = nil
+
+	post := stored.Post
+	return &post, nil
+}
+
+func (p *PostMemoryRepository) GetHistory(ctx context.Context, id int) (*models.PostRevisions, error) {
+	p.store.mu.RLock()
+	defer p.store.mu.RUnlock()
+
+	stored, ok := p.store.posts[int64(id)]
+	if !ok {
+		return nil, models.PostNotFound
+	}
+
+	revisions := models.PostRevisions{}
+	for _, revision := range stored.revisions {
+		copied := *revision
+		revisions = append(revisions, &copied)
+	}
+	if len(revisions) == 0 {
+		revisions = append(revisions, &models.PostRevision{
+			Revision: 1,
+			Message:  stored.Message,
+			Editor:   stored.Author,
+			Created:  stored.Created,
+		})
+	}
+	hideDeletedRevisions(&stored.Post, revisions)
+
+	return &revisions, nil
+}
+
+// hasPathPrefix аналог path[1:len(prefix)] = prefix
+func hasPathPrefix(path, prefix []int64) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	return comparePaths(path[:len(prefix)], prefix) == 0
+}
+
+func (p *PostMemoryRepository) GetUserPosts(ctx context.Context, nickname, limit, since, desc, forum string) (*models.Posts, error) {
+	p.store.mu.RLock()
+	defer p.store.mu.RUnlock()
+
+	if _, ok := p.store.users[key(nickname)]; !ok {
+		return nil, models.UserNotFound
+	}
+	n, err := parseLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	var sinceID int64
+	if since != "" {
+		if sinceID, err = strconv.ParseInt(since, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	isDesc := desc == "true"
+
+	found := make([]*memoryPost, 0)
+	for _, post := range p.store.posts {
+		if key(post.Author) != key(nickname) || post.Deleted || (forum != "" && key(post.Forum) != key(forum)) {
+			continue
+		}
+		if thread := p.store.threads[post.Thread]; thread == nil || thread.Deleted {
+			continue
+		}
+		if since != "" && ((isDesc && post.ID >= sinceID) || (!isDesc && post.ID <= sinceID)) {
+			continue
+		}
+		found = append(found, post)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if isDesc {
+			return found[i].ID > found[j].ID
+		}
+		return found[i].ID < found[j].ID
+	})
+	if len(found) > n {
+		found = found[:n]
+	}
+
+	posts := models.Posts{}
+	for _, stored := range found {
+		post := stored.Post
+		posts = append(posts, &post)
+	}
+	return &posts, nil
+}
+
+func (p *PostMemoryRepository) VotePost(ctx context.Context, id int, vote *models.Vote) (*models.Post, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	stored, ok := p.store.posts[int64(id)]
+	if !ok {
+		return nil, models.PostNotFound
+	}
+	if _, ok := p.store.users[key(vote.Nickname)]; !ok {
+		return nil, models.UserNotFound
+	}
+
+	votes, ok := p.store.postVotes[stored.ID]
+	if !ok {
+		votes = map[string]int32{}
+		p.store.postVotes[stored.ID] = votes
+	}
+	// триггер post_vote
+	delta := int32(vote.Voice) - votes[key(vote.Nickname)]
+	stored.Votes += delta
+	p.store.addKarma(stored.Author, delta)
+	votes[key(vote.Nickname)] = int32(vote.Voice)
+
+	post := stored.Post
+	voice := int32(vote.Voice)
+	post.Voice = &voice
+	post.HideDeleted()
+	return &post, nil
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/AntonPriyma/db_forum/metrics"
+	"github.com/AntonPriyma/db_forum/models"
+	"github.com/AntonPriyma/db_forum/utils"
+	"github.com/jackc/pgx"
+)
+
+// RolesRepository роли пользователей: администратор всего сервиса и модераторы отдельных форумов,
+// все остальные - обычные пользователи
+type RolesRepository interface {
+	IsAdmin(ctx context.Context, nickname string) (bool, error)
+	SetAdmin(ctx context.Context, nickname string, admin bool) error
+	// IsModerator модератор форума, владелец форума тоже считается модератором
+	IsModerator(ctx context.Context, forum, nickname string) (bool, error)
+	GrantModerator(ctx context.Context, forum, nickname string) error
+	RevokeModerator(ctx context.Context, forum, nickname string) error
+	// GetModerators назначенные модераторы форума по nickname, без владельца
+	GetModerators(ctx context.Context, forum string) (*models.Users, error)
+}
+
+type RolesRepositoryImpl struct {
+	db *pgx.ConnPool
+}
+
+func NewRolesRepositoryImpl(db *pgx.ConnPool) RolesRepository {
+	return &RolesRepositoryImpl{db: db}
+}
+
+func (r *RolesRepositoryImpl) IsAdmin(ctx context.Context, nickname string) (bool, error) {
+	defer metrics.ObserveQuery("roles", "IsAdmin", "", time.Now())
+	var admin bool
+	err := r.db.QueryRowEx(ctx, isAdminSQL, nil, nickname).Scan(&admin)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("nickname", nickname).Error("admin query failed")
+		return false, err
+	}
+	return admin, nil
+}
+
+func (r *RolesRepositoryImpl) SetAdmin(ctx context.Context, nickname string, admin bool) error {
+	defer metrics.ObserveQuery("roles", "SetAdmin", "", time.Now())
+	tag, err := r.db.ExecEx(ctx, setAdminSQL, nil, nickname, admin)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("nickname", nickname).Error("admin update failed")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.UserNotFound
+	}
+	return nil
+}
+
+func (r *RolesRepositoryImpl) IsModerator(ctx context.Context, forum, nickname string) (bool, error) {
+	defer metrics.ObserveQuery("roles", "IsModerator", "", time.Now())
+	var moderator bool
+	err := r.db.QueryRowEx(ctx, isModeratorSQL, nil, forum, nickname).Scan(&moderator)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("forum", forum).Error("moderator query failed")
+		return false, err
+	}
+	return moderator, nil
+}
+
+func (r *RolesRepositoryImpl) GrantModerator(ctx context.Context, forum, nickname string) error {
+	defer metrics.ObserveQuery("roles", "GrantModerator", "", time.Now())
+	tag, err := r.db.ExecEx(ctx, grantModeratorSQL, nil, forum, nickname)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("forum", forum).Error("moderator insert failed")
+		return err
+	}
+	if tag.RowsAffected() != 0 {
+		return nil
+	}
+
+	// ничего не вставилось: нет форума, нет пользователя или он уже модератор
+	if !r.forumExists(ctx, forum) {
+		return models.ForumNotFound
+	}
+	var found string
+	if err = r.db.QueryRowEx(ctx, getUserSQL, nil, nickname).Scan(&found, &found, &found, &found); err != nil {
+		return models.UserNotFound
+	}
+	return nil
+}
+
+func (r *RolesRepositoryImpl) RevokeModerator(ctx context.Context, forum, nickname string) error {
+	defer metrics.ObserveQuery("roles", "RevokeModerator", "", time.Now())
+	tag, err := r.db.ExecEx(ctx, revokeModeratorSQL, nil, forum, nickname)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("forum", forum).Error("moderator delete failed")
+		return err
+	}
+	if tag.RowsAffected() != 0 {
+		return nil
+	}
+	if !r.forumExists(ctx, forum) {
+		return models.ForumNotFound
+	}
+	return models.ModeratorNotFound
+}
+
+func (r *RolesRepositoryImpl) GetModerators(ctx context.Context, forum string) (*models.Users, error) {
+	defer metrics.ObserveQuery("roles", "GetModerators", "", time.Now())
+	rows, err := r.db.QueryEx(ctx, getModeratorsSQL, nil, forum)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("forum", forum).Error("moderators query failed")
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := models.Users{}
+	for rows.Next() {
+		user := models.User{}
+		if err = rows.Scan(&user.Nickname, &user.Fullname, &user.Email, &user.About, &user.Karma); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 && !r.forumExists(ctx, forum) {
+		return nil, models.ForumNotFound
+	}
+	return &users, nil
+}
+
+func (r *RolesRepositoryImpl) forumExists(ctx context.Context, forum string) bool {
+	var slug string
+	return r.db.QueryRowEx(ctx, `SELECT slug FROM forums WHERE slug = $1`, nil, forum).Scan(&slug) == nil
+}
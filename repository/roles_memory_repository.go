@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/AntonPriyma/db_forum/models"
+)
+
+type RolesMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewRolesMemoryRepository(store *MemoryStore) RolesRepository {
+	return &RolesMemoryRepository{store: store}
+}
+
+func (r *RolesMemoryRepository) IsAdmin(ctx context.Context, nickname string) (bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.store.admins[key(nickname)], nil
+}
+
+func (r *RolesMemoryRepository) SetAdmin(ctx context.Context, nickname string, admin bool) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.users[key(nickname)]; !ok {
+		return models.UserNotFound
+	}
+	if admin {
+		r.store.admins[key(nickname)] = true
+	} else {
+		delete(r.store.admins, key(nickname))
+	}
+	return nil
+}
+
+func (r *RolesMemoryRepository) IsModerator(ctx context.Context, forum, nickname string) (bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if existing, ok := r.store.forums[key(forum)]; ok && key(existing.Owner) == key(nickname) {
+		return true, nil
+	}
+	return r.store.moderators[key(forum)][key(nickname)], nil
+}
+
+func (r *RolesMemoryRepository) GrantModerator(ctx context.Context, forum, nickname string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.forums[key(forum)]; !ok {
+		return models.ForumNotFound
+	}
+	if _, ok := r.store.users[key(nickname)]; !ok {
+		return models.UserNotFound
+	}
+	moderators, ok := r.store.moderators[key(forum)]
+	if !ok {
+		moderators = map[string]bool{}
+		r.store.moderators[key(forum)] = moderators
+	}
+	moderators[key(nickname)] = true
+	return nil
+}
+
+func (r *RolesMemoryRepository) RevokeModerator(ctx context.Context, forum, nickname string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.forums[key(forum)]; !ok {
+		return models.ForumNotFound
+	}
+	if !r.store.moderators[key(forum)][key(nickname)] {
+		return models.ModeratorNotFound
+	}
+	delete(r.store.moderators[key(forum)], key(nickname))
+	return nil
+}
+
+func (r *RolesMemoryRepository) GetModerators(ctx context.Context, forum string) (*models.Users, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if _, ok := r.store.forums[key(forum)]; !ok {
+		return nil, models.ForumNotFound
+	}
+	found := make([]*models.User, 0)
+	for nickname := range r.store.moderators[key(forum)] {
+		found = append(found, r.store.users[nickname])
+	}
+	return sortUsers(found, false, len(found)), nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/AntonPriyma/db_forum/metrics"
+	"github.com/AntonPriyma/db_forum/models"
+	"github.com/AntonPriyma/db_forum/utils"
+	"github.com/jackc/pgx"
+)
+
+type SearchRepository interface {
+	// Search полнотекстовый поиск по постам и тредам, скрытые не ищутся
+	Search(ctx context.Context, query *models.SearchQuery) (*models.SearchResults, error)
+}
+
+type SearchRepositoryImpl struct {
+	db *pgx.ConnPool
+}
+
+func NewSearchRepositoryImpl(db *pgx.ConnPool) SearchRepository {
+	return &SearchRepositoryImpl{db: db}
+}
+
+func (s *SearchRepositoryImpl) Search(ctx context.Context, query *models.SearchQuery) (*models.SearchResults, error) {
+	variant := query.Type
+	if variant == "" {
+		variant = "all"
+	}
+	defer metrics.ObserveQuery("search", "Search", variant, time.Now())
+
+	var since, until string
+	if query.Since != nil {
+		since = query.Since.Format(time.RFC3339Nano)
+	}
+	if query.Until != nil {
+		until = query.Until.Format(time.RFC3339Nano)
+	}
+
+	rows, err := s.db.QueryEx(ctx, searchSQL, nil,
+		query.Query,
+		query.Limit,
+		query.Type,
+		query.Forum,
+		query.Thread,
+		query.Author,
+		since,
+		until,
+		query.Offset,
+	)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).Error("search query failed")
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := models.SearchResults{}
+	for rows.Next() {
+		r := models.SearchResult{}
+		err = rows.Scan(
+			&r.Type,
+			&r.ID,
+			&r.Thread,
+			&r.Forum,
+			&r.Author,
+			&r.Created,
+			&r.Rank,
+			&r.Snippet,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &r)
+	}
+	if err = rows.Err(); err != nil {
+		utils.Logger(ctx).WithError(err).Error("search query failed")
+		return nil, err
+	}
+
+	return &results, nil
+}
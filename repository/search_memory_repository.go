@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/AntonPriyma/db_forum/models"
+)
+
+type SearchMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewSearchMemoryRepository(store *MemoryStore) SearchRepository {
+	return &SearchMemoryRepository{store: store}
+}
+
+// Search упрощённый аналог tsvector: слова в нижнем регистре, должны встретиться все слова запроса,
+// ранг - число вхождений
+func (s *SearchMemoryRepository) Search(ctx context.Context, query *models.SearchQuery) (*models.SearchResults, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	terms := map[string]bool{}
+	for _, term := range searchWords(query.Query) {
+		terms[term] = true
+	}
+
+	found := make([]*models.SearchResult, 0)
+	match := func(kind string, id int64, thread int32, forum, author string, created time.Time, body string) {
+		if query.Type != "" && query.Type != kind {
+			return
+		}
+		if query.Forum != "" && key(query.Forum) != key(forum) {
+			return
+		}
+		if query.Thread != 0 && query.Thread != thread {
+			return
+		}
+		if query.Author != "" && key(query.Author) != key(author) {
+			return
+		}
+		if (query.Since != nil && created.Before(*query.Since)) || (query.Until != nil && created.After(*query.Until)) {
+			return
+		}
+		rank := searchRank(body, terms)
+		if rank == 0 {
+			return
+		}
+		found = append(found, &models.SearchResult{
+			Type:    kind,
+			ID:      id,
+			Thread:  thread,
+			Forum:   forum,
+			Author:  author,
+			Created: created,
+			Rank:    rank,
+			Snippet: highlightWords(body, terms),
+		})
+	}
+
+	for _, post := range s.store.posts {
+		// посты скрытого треда не ищутся, как JOIN threads в searchSQL
+		if thread := s.store.threads[post.Thread]; !post.Deleted && thread != nil && !thread.Deleted {
+			match(models.SearchPost, post.ID, post.Thread, post.Forum, post.Author, post.Created, post.Message)
+		}
+	}
+	for _, thread := range s.store.threads {
+		if !thread.Deleted {
+			match(models.SearchThread, int64(thread.ID), thread.ID, thread.Forum, thread.Author, thread.Created, thread.Title+" "+thread.Message)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		switch {
+		case a.Rank != b.Rank:
+			return a.Rank > b.Rank
+		case !a.Created.Equal(b.Created):
+			return a.Created.After(b.Created)
+		case a.Type != b.Type:
+			return a.Type < b.Type
+		default:
+			return a.ID > b.ID
+		}
+	})
+
+	offset := query.Offset
+	if offset > len(found) {
+		offset = len(found)
+	}
+	found = found[offset:]
+	if len(found) > query.Limit {
+		found = found[:query.Limit]
+	}
+
+	results := models.SearchResults(found)
+	return &results, nil
+}
+
+// searchWords слова текста в нижнем регистре, как парсер to_tsvector для конфигурации simple
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchRank число вхождений слов запроса, 0 если какого-то слова нет, как plainto_tsquery
+func searchRank(body string, terms map[string]bool) float32 {
+	if len(terms) == 0 {
+		return 0
+	}
+	seen := map[string]bool{}
+	var rank float32
+	for _, word := range searchWords(body) {
+		if terms[word] {
+			seen[word] = true
+			rank++
+		}
+	}
+	if len(seen) != len(terms) {
+		return 0
+	}
+	return rank
+}
+
+// highlightWords оборачивает слова запроса в <b></b>, как ts_headline, остальной текст экранирует
+func highlightWords(body string, terms map[string]bool) string {
+	var out strings.Builder
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := body[start:end]
+		if terms[strings.ToLower(word)] {
+			out.WriteString("<b>" + word + "</b>")
+		} else {
+			out.WriteString(word)
+		}
+		start = -1
+	}
+	for i, r := range body {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+		out.WriteString(html.EscapeString(string(r)))
+	}
+	flush(len(body))
+	return out.String()
+}
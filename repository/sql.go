@@ -3,11 +3,16 @@ package repository
 const (
 	createUserSQL = `
 		INSERT
-		INTO users ("nickname", "fullname", "email", "about")
-		VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING
+		INTO users ("nickname", "fullname", "email", "about", "password_hash")
+		VALUES ($1, $2, $3, $4, nullif($5, '')) ON CONFLICT DO NOTHING
+	`
+	getUserPasswordSQL = `
+		SELECT "nickname", coalesce("password_hash", '')
+		FROM users
+		WHERE "nickname" = $1
 	`
 	getUserByNicknameOrEmailSQL = `
-		SELECT "nickname", "fullname", "email", "about"
+		SELECT "nickname", "fullname", "email", "about", "karma"
 		FROM users
 		WHERE "nickname" = $1 OR "email" = $2
 	`
@@ -17,7 +22,7 @@ const (
 		WHERE "nickname" = $1
 	`
 	getUserSQL = `
-		SELECT "nickname", "fullname", "email", "about"
+		SELECT "nickname", "fullname", "email", "about", "karma"
 		FROM users
 		WHERE "nickname" = $1
 	`
@@ -27,29 +32,112 @@ const (
 			email = coalesce(nullif($3, ''), email),
 			about = coalesce(nullif($4, ''), about)
 		WHERE "nickname" = $1
-		RETURNING nickname, fullname, email, about
+		RETURNING nickname, fullname, email, about, karma
+	`
+	getUsersSQL = `
+		SELECT nickname, fullname, email, about, karma
+		FROM users
+		ORDER BY nickname COLLATE ucs_basic
+		LIMIT $1::TEXT::INTEGER
+	`
+	getUsersDescSQL = `
+		SELECT nickname, fullname, email, about, karma
+		FROM users
+		ORDER BY nickname COLLATE ucs_basic DESC
+		LIMIT $1::TEXT::INTEGER
+	`
+	getUsersSinceSQL = `
+		SELECT nickname, fullname, email, about, karma
+		FROM users
+		WHERE nickname COLLATE ucs_basic > $2::TEXT::CITEXT
+		ORDER BY nickname COLLATE ucs_basic
+		LIMIT $1::TEXT::INTEGER
+	`
+	getUsersDescSinceSQL = `
+		SELECT nickname, fullname, email, about, karma
+		FROM users
+		WHERE nickname COLLATE ucs_basic < $2::TEXT::CITEXT
+		ORDER BY nickname COLLATE ucs_basic DESC
+		LIMIT $1::TEXT::INTEGER
+	`
+	// $1 префикс в нижнем регистре с экранированными % и _ и с % на конце
+	searchUsersSQL = `
+		SELECT nickname, fullname, email, about, karma
+		FROM users
+		WHERE lower(nickname::TEXT) LIKE $1
+			OR lower(fullname::TEXT) LIKE $1
+			OR lower(email::TEXT) LIKE $1
+		ORDER BY nickname COLLATE ucs_basic
+		LIMIT $2::TEXT::INTEGER
+	`
+	// посты пользователя, $3 - id последнего поста предыдущей страницы, $4 - необязательный форум.
+	// Скрытые посты и посты скрытых тредов не отдаются
+	getUserPostsSQL = `
+		SELECT id, author, parent, message, forum, thread, created, "isEdited", votes, deleted, coalesce(deleted_by, ''), deleted_at
+		FROM posts
+		WHERE author = $1 AND NOT deleted
+			AND NOT EXISTS (SELECT 1 FROM threads t WHERE t.id = posts.thread AND t.deleted)
+			AND id > coalesce(nullif($3, '')::BIGINT, 0)
+			AND (nullif($4, '') IS NULL OR forum = $4::TEXT::CITEXT)
+		ORDER BY id
+		LIMIT $2::TEXT::INTEGER
+	`
+	getUserPostsDescSQL = `
+		SELECT id, author, parent, message, forum, thread, created, "isEdited", votes, deleted, coalesce(deleted_by, ''), deleted_at
+		FROM posts
+		WHERE author = $1 AND NOT deleted
+			AND NOT EXISTS (SELECT 1 FROM threads t WHERE t.id = posts.thread AND t.deleted)
+			AND id < coalesce(nullif($3, '')::BIGINT, 9223372036854775807)
+			AND (nullif($4, '') IS NULL OR forum = $4::TEXT::CITEXT)
+		ORDER BY id DESC
+		LIMIT $2::TEXT::INTEGER
+	`
+	// треды пользователя, since - id последнего треда прошлой страницы, ключ (created, id)
+	// как в idx_threads_author_created. Неизвестный since листает с начала
+	getUserThreadsSQL = `
+		SELECT author, created, forum, id, message, slug, title, votes, deleted, coalesce(deleted_by, ''), deleted_at
+		FROM threads
+		WHERE author = $1 AND NOT deleted
+			AND (created, id) > (
+				coalesce((SELECT created FROM threads WHERE id = nullif($3, '')::INTEGER), '-infinity'),
+				coalesce(nullif($3, '')::INTEGER, 0))
+			AND (nullif($4, '') IS NULL OR forum = $4::TEXT::CITEXT)
+		ORDER BY created, id
+		LIMIT $2::TEXT::INTEGER
+	`
+	getUserThreadsDescSQL = `
+		SELECT author, created, forum, id, message, slug, title, votes, deleted, coalesce(deleted_by, ''), deleted_at
+		FROM threads
+		WHERE author = $1 AND NOT deleted
+			AND (created, id) < (
+				coalesce((SELECT created FROM threads WHERE id = nullif($3, '')::INTEGER), 'infinity'),
+				coalesce(nullif($3, '')::INTEGER, 2147483647))
+			AND (nullif($4, '') IS NULL OR forum = $4::TEXT::CITEXT)
+		ORDER BY created DESC, id DESC
+		LIMIT $2::TEXT::INTEGER
 	`
 	getThreadSlugSQL = `
-		SELECT id, title, author, forum, message, votes, slug, created
+		SELECT id, title, author, forum, message, votes, slug, created, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM threads
 		WHERE slug = $1
 	`
 	getThreadIdSQL = `
-		SELECT id, title, author, forum, message, votes, slug, created
+		SELECT id, title, author, forum, message, votes, slug, created, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM threads
 		WHERE id = $1
 	`
 	updateThreadSQL = `
 		UPDATE threads
 		SET title = coalesce(nullif($2, ''), title),
-			message = coalesce(nullif($3, ''), message)
-		WHERE slug = $1
-		RETURNING id, title, author, forum, message, votes, slug, created
+			message = coalesce(nullif($3, ''), message),
+			slug = coalesce(nullif($4, ''), slug)
+		WHERE id = $1
+		RETURNING id, title, author, forum, message, votes, slug, created, deleted, coalesce(deleted_by, ''), deleted_at
 	`
 
 	// getThreadPosts
 	getPostsSienceDescLimitTreeSQL = `
-		SELECT id, author, parent, message, forum, thread, created
+		SELECT id, author, parent, message, forum, thread, created, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts
 		WHERE thread = $1 AND (path < (SELECT path FROM posts WHERE id = $2::TEXT::INTEGER))
 		ORDER BY path DESC
@@ -57,7 +145,7 @@ const (
 	`
 
 	getPostsSienceDescLimitParentTreeSQL = `
-		SELECT id, author, parent, message, forum, thread, created
+		SELECT id, author, parent, message, forum, thread, created, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts p
 		WHERE p.thread = $1 and p.path[1] IN (
 			SELECT p2.path[1]
@@ -70,15 +158,15 @@ const (
 	`
 
 	getPostsSienceDescLimitFlatSQL = `
-		SELECT id, author, parent, message, forum, thread, created
+		SELECT id, author, parent, message, forum, thread, created, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts
-		WHERE thread = $1 AND id < $2::TEXT::INTEGER
+		WHERE thread = $1 AND NOT deleted AND id < $2::TEXT::INTEGER
 		ORDER BY id DESC
 		LIMIT $3::TEXT::INTEGER
 	`
 
 	getPostsSienceLimitTreeSQL = `
-		SELECT id, author, parent, message, forum, thread, created
+		SELECT id, author, parent, message, forum, thread, created, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts
 		WHERE thread = $1 AND (path > (SELECT path FROM posts WHERE id = $2::TEXT::INTEGER))
 		ORDER BY path
@@ -86,7 +174,7 @@ const (
 	`
 
 	getPostsSienceLimitParentTreeSQL = `
-		SELECT id, author, parent, message, forum, thread, created
+		SELECT id, author, parent, message, forum, thread, created, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts p
 		WHERE p.thread = $1 and p.path[1] IN (
 			SELECT p2.path[1]
@@ -98,22 +186,22 @@ const (
 		ORDER BY p.path
 	`
 	getPostsSienceLimitFlatSQL = `
-		SELECT id, author, parent, message, forum, thread, created
+		SELECT id, author, parent, message, forum, thread, created, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts
-		WHERE thread = $1 AND id > $2::TEXT::INTEGER
+		WHERE thread = $1 AND NOT deleted AND id > $2::TEXT::INTEGER
 		ORDER BY id
 		LIMIT $3::TEXT::INTEGER
 	`
 	// without sience
 	getPostsDescLimitTreeSQL = `
-		SELECT id, author, parent, message, forum, thread, created
+		SELECT id, author, parent, message, forum, thread, created, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts
 		WHERE thread = $1 
 		ORDER BY path DESC
 		LIMIT $2::TEXT::INTEGER
 	`
 	getPostsDescLimitParentTreeSQL = `
-		SELECT id, author, parent, message, forum, thread, created
+		SELECT id, author, parent, message, forum, thread, created, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts
 		WHERE thread = $1 AND path[1] IN (
 			SELECT path[1]
@@ -126,21 +214,21 @@ const (
 		ORDER BY path[1] DESC, path
 	`
 	getPostsDescLimitFlatSQL = `
-		SELECT id, author, parent, message, forum, thread, created
+		SELECT id, author, parent, message, forum, thread, created, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts
-		WHERE thread = $1
+		WHERE thread = $1 AND NOT deleted
 		ORDER BY id DESC
 		LIMIT $2::TEXT::INTEGER
 	`
 	getPostsLimitTreeSQL = `
-		SELECT id, author, parent, message, forum, thread, created
+		SELECT id, author, parent, message, forum, thread, created, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts
 		WHERE thread = $1 
 		ORDER BY path
 		LIMIT $2::TEXT::INTEGER
 	`
 	getPostsLimitParentTreeSQL = `
-		SELECT id, author, parent, message, forum, thread, created
+		SELECT id, author, parent, message, forum, thread, created, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts
 		WHERE thread = $1 AND path[1] IN (
 			SELECT path[1] 
@@ -153,37 +241,143 @@ const (
 		ORDER BY path
 	`
 	getPostsLimitFlatSQL = `
-		SELECT id, author, parent, message, forum, thread, created
+		SELECT id, author, parent, message, forum, thread, created, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts
-		WHERE thread = $1 
+		WHERE thread = $1 AND NOT deleted 
 		ORDER BY id
 		LIMIT $2::TEXT::INTEGER
 	`
 
 	getPostSQL = `
-		SELECT id, author, message, forum, thread, created, "isEdited", parent
+		SELECT id, author, message, forum, thread, created, "isEdited", parent, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM posts 
 		WHERE id = $1
 	`
+	updateForumPostsCountSQL = `
+		UPDATE forums
+		SET posts = posts + $1
+		WHERE slug IN (SELECT forum_lineage($2))
+	`
+	addForumUsersSQL = `
+		INSERT INTO forum_users ("forum_user", "forum", "email", "fullname", "about")
+		SELECT nickname, $2, email, fullname, about
+		FROM users
+		WHERE nickname = ANY($1::TEXT[]::CITEXT[])
+		ON CONFLICT DO NOTHING
+	`
 	updatePostSQL = `
 		UPDATE posts 
 		SET message = COALESCE($2, message), "isEdited" = ($2 IS NOT NULL AND $2 <> message) 
 		WHERE id = $1 
-		RETURNING author::text, created, forum, "isEdited", thread, message, parent
+		RETURNING author::text, created, forum, "isEdited", thread, message, parent, votes, deleted, coalesce(deleted_by, ''), deleted_at
 	`
+	// несуществующий родитель вставляется как есть и ломается на внешнем ключе
 	createForumSQL = `
-		INSERT INTO forums (slug, title, "user")
+		INSERT INTO forums (slug, title, "user", parent)
 		VALUES ($1, $2, (
 			SELECT nickname FROM users WHERE nickname = $3
-		)) 
-		RETURNING "user"
+		), CASE WHEN $4::TEXT = '' THEN NULL ELSE coalesce(
+			(SELECT slug FROM forums WHERE slug = $4::TEXT::CITEXT), $4::TEXT::CITEXT
+		) END) 
+		RETURNING "user", coalesce(parent, '')
 	`
 
 	getForumSQL = `
-		SELECT slug, title, "user", posts, threads
+		SELECT slug, title, "user", posts, threads, archived, coalesce(parent, '')
 		FROM forums
 		WHERE slug = $1
 	`
+	// getForumsTemplateSQL: условие since, выражение сортировки и два направления
+	getForumsTemplateSQL = `
+		SELECT slug, title, "user", posts, threads, archived, coalesce(parent, '')
+		FROM forums
+		%s
+		ORDER BY %s %s, slug %s
+		LIMIT $1::TEXT::INTEGER
+	`
+	getForumsSinceSlugSQL = `WHERE slug %s $2::TEXT::CITEXT`
+	getForumsSinceSQL     = `WHERE (%s, slug) %s (SELECT %s, slug FROM forums WHERE slug = $2::TEXT::CITEXT)`
+
+	// пустой $3 оставляет владельца, несуществующий даёт NULL и PgxErrNotNull
+	updateForumSQL = `
+		UPDATE forums
+		SET title = coalesce(nullif($2, ''), title),
+			"user" = CASE WHEN $3::TEXT = '' THEN "user" ELSE (SELECT nickname FROM users WHERE nickname = $3::TEXT::CITEXT) END,
+			slug = coalesce(nullif($4, ''), slug)
+		WHERE slug = $1
+		RETURNING slug, title, "user", posts, threads, archived, coalesce(parent, '')
+	`
+	// forum_users без внешнего ключа на forums, поэтому ON UPDATE CASCADE его не задевает
+	renameForumUsersSQL = `
+		UPDATE forum_users
+		SET forum = $2
+		WHERE forum = $1
+	`
+	lockForumSQL = `
+		SELECT slug, coalesce(parent, ''), EXISTS(SELECT 1 FROM forums WHERE parent = $1), threads, posts
+		FROM forums
+		WHERE slug = $1
+		FOR UPDATE
+	`
+	getForumChildrenSQL = `
+		SELECT slug, title, "user", posts, threads, archived, coalesce(parent, '')
+		FROM forums
+		WHERE parent = $1
+		ORDER BY slug
+	`
+	// предки без самого форума, от корня вниз. path обрывает цикл, если он всё же есть в базе
+	getForumBreadcrumbsSQL = `
+		WITH RECURSIVE lineage AS (
+			SELECT slug, title, parent, 0 AS depth, ARRAY[$1::TEXT::CITEXT, slug] AS path
+			FROM forums
+			WHERE slug = (SELECT parent FROM forums WHERE slug = $1)
+			UNION ALL
+			SELECT f.slug, f.title, f.parent, l.depth + 1, l.path || f.slug
+			FROM forums f
+			JOIN lineage l ON f.slug = l.parent
+			WHERE NOT f.slug = ANY(l.path)
+		)
+		SELECT slug, title
+		FROM lineage
+		ORDER BY depth DESC
+	`
+	// архивируется форум вместе с подфорумами, треды и посты остаются видны,
+	// запись в них запрещает триггер forum_archived
+	archiveForumSQL = `
+		WITH RECURSIVE subtree AS (
+			SELECT slug, ARRAY[slug] AS path
+			FROM forums
+			WHERE slug = $1
+			UNION ALL
+			SELECT f.slug, s.path || f.slug
+			FROM forums f
+			JOIN subtree s ON f.parent = s.slug
+			WHERE NOT f.slug = ANY(s.path)
+		)
+		UPDATE forums
+		SET archived = TRUE
+		WHERE slug IN (SELECT slug FROM subtree)
+	`
+	deleteForumVotesSQL = `
+		DELETE FROM votes
+		WHERE thread IN (SELECT id FROM threads WHERE forum = $1)
+	`
+	deleteForumPostsSQL = `
+		DELETE FROM posts
+		WHERE forum = $1
+	`
+	deleteForumThreadsSQL = `
+		DELETE FROM threads
+		WHERE forum = $1
+	`
+	deleteForumUsersSQL = `
+		DELETE FROM forum_users
+		WHERE forum = $1
+	`
+	deleteForumSQL = `
+		DELETE FROM forums
+		WHERE slug = $1
+	`
 
 	createForumThreadSQL = `
 		INSERT INTO threads (author, created, message, title, slug, forum)
@@ -192,61 +386,248 @@ const (
 	`
 
 	getForumThreadsSinceSQL = `
-		SELECT author, created, forum, id, message, slug, title, votes
+		SELECT author, created, forum, id, message, slug, title, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM threads
-		WHERE forum = $1 AND created >= $2::TEXT::TIMESTAMPTZ
+		WHERE forum = $1 AND created >= $2::TEXT::TIMESTAMPTZ AND (NOT deleted OR $4::TEXT::BOOLEAN)
 		ORDER BY created
 		LIMIT $3::TEXT::INTEGER
 	`
 	getForumThreadsDescSinceSQL = `
-		SELECT author, created, forum, id, message, slug, title, votes
+		SELECT author, created, forum, id, message, slug, title, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM threads
-		WHERE forum = $1 AND created <= $2::TEXT::TIMESTAMPTZ
+		WHERE forum = $1 AND created <= $2::TEXT::TIMESTAMPTZ AND (NOT deleted OR $4::TEXT::BOOLEAN)
 		ORDER BY created DESC
 		LIMIT $3::TEXT::INTEGER
 	`
 	getForumThreadsSQL = `
-		SELECT author, created, forum, id, message, slug, title, votes
+		SELECT author, created, forum, id, message, slug, title, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM threads
-		WHERE forum = $1
+		WHERE forum = $1 AND (NOT deleted OR $3::TEXT::BOOLEAN)
 		ORDER BY created
 		LIMIT $2::TEXT::INTEGER
 	`
 	getForumThreadsDescSQL = `
-		SELECT author, created, forum, id, message, slug, title, votes
+		SELECT author, created, forum, id, message, slug, title, votes, deleted, coalesce(deleted_by, ''), deleted_at
 		FROM threads
-		WHERE forum = $1
+		WHERE forum = $1 AND (NOT deleted OR $3::TEXT::BOOLEAN)
 		ORDER BY created DESC
 		LIMIT $2::TEXT::INTEGER
 	`
 	getForumUsersSinceSQl = `
-		SELECT forum_user, fullname, about, email
-		FROM forum_users
-		WHERE forum = $1
-		AND LOWER(forum_user) > LOWER($2::TEXT)
-		ORDER BY forum_user
+		SELECT f.forum_user, f.fullname, f.about, f.email, u.karma
+		FROM forum_users f
+		JOIN users u ON u.nickname = f.forum_user
+		WHERE f.forum = $1
+		AND LOWER(f.forum_user) > LOWER($2::TEXT)
+		ORDER BY f.forum_user
 		LIMIT $3::TEXT::INTEGER
 	`
 	getForumUsersDescSinceSQl = `
-		SELECT forum_user, fullname, about, email
-		FROM forum_users
-		WHERE forum = $1
-		AND LOWER(forum_user) < LOWER($2::TEXT)
-		ORDER BY forum_user DESC
+		SELECT f.forum_user, f.fullname, f.about, f.email, u.karma
+		FROM forum_users f
+		JOIN users u ON u.nickname = f.forum_user
+		WHERE f.forum = $1
+		AND LOWER(f.forum_user) < LOWER($2::TEXT)
+		ORDER BY f.forum_user DESC
 		LIMIT $3::TEXT::INTEGER
 	`
 	getForumUsersSQl = `
-		SELECT forum_user, fullname, about, email
-		FROM forum_users
-		WHERE forum = $1
-		ORDER BY forum_user
+		SELECT f.forum_user, f.fullname, f.about, f.email, u.karma
+		FROM forum_users f
+		JOIN users u ON u.nickname = f.forum_user
+		WHERE f.forum = $1
+		ORDER BY f.forum_user
 		LIMIT $2::TEXT::INTEGER
 	`
 	getForumUsersDescSQl = `
-		SELECT forum_user, fullname, about, email
-		FROM forum_users
-		WHERE forum = $1
-		ORDER BY forum_user DESC
+		SELECT f.forum_user, f.fullname, f.about, f.email, u.karma
+		FROM forum_users f
+		JOIN users u ON u.nickname = f.forum_user
+		WHERE f.forum = $1
+		ORDER BY f.forum_user DESC
 		LIMIT $2::TEXT::INTEGER
 	`
+
+	getPostForDeleteSQL = `
+		SELECT id, author, message, forum, thread, created, "isEdited", parent, path, votes, deleted, coalesce(deleted_by, ''), deleted_at
+		FROM posts
+		WHERE id = $1
+		FOR UPDATE
+	`
+	// поддерево поста: path начинается с его path, сам пост не входит
+	hasPostRepliesSQL = `
+		SELECT EXISTS(
+			SELECT 1 FROM posts
+			WHERE thread = $1 AND path > $2 AND path[1:$3] = $2
+		)
+	`
+	deletePostSubtreeSQL = `
+		DELETE FROM posts
+		WHERE thread = $1 AND path >= $2 AND path[1:$3] = $2
+	`
+	reparentPostRepliesSQL = `
+		UPDATE posts
+		SET parent = CASE WHEN parent = $4::BIGINT THEN $5::INTEGER ELSE parent END,
+			path = array_remove(path, $4::BIGINT)
+		WHERE thread = $1 AND path > $2 AND path[1:$3] = $2
+	`
+	// заглушка при удалении с tombstone: пост скрывается так же, как модератором, но без deleted_by
+	tombstonePostSQL = `
+		UPDATE posts
+		SET deleted = TRUE, deleted_at = now()
+		WHERE id = $1
+		RETURNING deleted_at
+	`
+	hidePostSQL = `
+		UPDATE posts
+		SET deleted = TRUE, deleted_by = $2, deleted_at = now()
+		WHERE id = $1
+		RETURNING id, author, message, forum, thread, created, "isEdited", parent, votes, deleted, coalesce(deleted_by, ''), deleted_at
+	`
+	restorePostSQL = `
+		UPDATE posts
+		SET deleted = FALSE, deleted_by = NULL, deleted_at = NULL
+		WHERE id = $1
+		RETURNING id, author, message, forum, thread, created, "isEdited", parent, votes, deleted, coalesce(deleted_by, ''), deleted_at
+	`
+	hideThreadSQL = `
+		UPDATE threads
+		SET deleted = TRUE, deleted_by = $2, deleted_at = now()
+		WHERE id = $1
+		RETURNING id, title, author, forum, message, votes, slug, created, deleted, coalesce(deleted_by, ''), deleted_at
+	`
+	restoreThreadSQL = `
+		UPDATE threads
+		SET deleted = FALSE, deleted_by = NULL, deleted_at = NULL
+		WHERE id = $1
+		RETURNING id, title, author, forum, message, votes, slug, created, deleted, coalesce(deleted_by, ''), deleted_at
+	`
+	deletePostSQL = `
+		DELETE FROM posts
+		WHERE id = $1
+	`
+	// upsert голоса за пост, автор копируется для триггера post_vote. Ни одной строки - нет поста или пользователя
+	votePostSQL = `
+		INSERT INTO post_votes ("post", "author", "nickname", "voice")
+		SELECT p.id, p.author, u.nickname, $3::INTEGER
+		FROM posts p, users u
+		WHERE p.id = $1 AND u.nickname = $2
+		ON CONFLICT ("post", "nickname") DO UPDATE SET voice = EXCLUDED.voice
+	`
+	deleteVoteSQL = `
+		DELETE FROM votes
+		WHERE thread = $1 AND nickname = $2
+	`
+	deleteThreadVotesSQL = `
+		DELETE FROM votes
+		WHERE thread = $1
+	`
+	deleteThreadPostsSQL = `
+		DELETE FROM posts
+		WHERE thread = $1
+	`
+	deleteThreadSQL = `
+		DELETE FROM threads
+		WHERE id = $1
+	`
+	updateForumCountersSQL = `
+		UPDATE forums
+		SET threads = threads + $1, posts = posts + $2
+		WHERE slug IN (SELECT forum_lineage($3))
+	`
+	getPostForUpdateSQL = `
+		SELECT author, message, created
+		FROM posts
+		WHERE id = $1
+		FOR UPDATE
+	`
+	// исходный текст сохраняется ревизией 1 при первой правке
+	addPostOriginalRevisionSQL = `
+		INSERT INTO post_revisions (post, revision, message, editor, created)
+		SELECT $1, 1, $2, $3, $4
+		WHERE NOT EXISTS (SELECT 1 FROM post_revisions WHERE post = $1)
+	`
+	addPostRevisionSQL = `
+		INSERT INTO post_revisions (post, revision, message, editor)
+		SELECT $1, max(revision) + 1, $2, $3
+		FROM post_revisions
+		WHERE post = $1
+	`
+	getPostRevisionsSQL = `
+		SELECT revision, message, editor, created
+		FROM post_revisions
+		WHERE post = $1
+		ORDER BY revision
+	`
+
+	// searchSQL: сначала ранжирование и пагинация, ts_headline только для попавших на страницу.
+	// Текст экранируется до ts_headline, как html.EscapeString, чтобы в сниппете HTML был только <b>.
+	// $3 тип (post, thread или пусто), пустые фильтры и нулевой тред не применяются
+	searchSQL = `
+		SELECT kind, id, thread, forum, author, created, rank,
+			ts_headline('pg_catalog.simple',
+				replace(replace(replace(replace(replace(body,
+					'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;'),
+				plainto_tsquery('pg_catalog.simple', $1),
+				'StartSel=<b>, StopSel=</b>, MaxWords=35, MinWords=15')
+		FROM (
+			SELECT 'post' AS kind, p.id, p.thread, p.forum, p.author, p.created,
+				ts_rank(p.search, q) AS rank, p.message AS body
+			FROM posts p
+			JOIN threads pt ON pt.id = p.thread AND NOT pt.deleted,
+				plainto_tsquery('pg_catalog.simple', $1) q
+			WHERE p.search @@ q AND NOT p.deleted AND $3 IN ('', 'post')
+				AND (nullif($4, '') IS NULL OR p.forum = $4::TEXT::CITEXT)
+				AND ($5::INTEGER = 0 OR p.thread = $5::INTEGER)
+				AND (nullif($6, '') IS NULL OR p.author = $6::TEXT::CITEXT)
+				AND p.created >= coalesce(nullif($7, '')::TIMESTAMPTZ, '-infinity')
+				AND p.created <= coalesce(nullif($8, '')::TIMESTAMPTZ, 'infinity')
+			UNION ALL
+			SELECT 'thread', t.id, t.id, t.forum, t.author, t.created,
+				ts_rank(t.search, q), t.title || ' ' || t.message
+			FROM threads t, plainto_tsquery('pg_catalog.simple', $1) q
+			WHERE t.search @@ q AND NOT t.deleted AND $3 IN ('', 'thread')
+				AND (nullif($4, '') IS NULL OR t.forum = $4::TEXT::CITEXT)
+				AND ($5::INTEGER = 0 OR t.id = $5::INTEGER)
+				AND (nullif($6, '') IS NULL OR t.author = $6::TEXT::CITEXT)
+				AND t.created >= coalesce(nullif($7, '')::TIMESTAMPTZ, '-infinity')
+				AND t.created <= coalesce(nullif($8, '')::TIMESTAMPTZ, 'infinity')
+			ORDER BY rank DESC, created DESC, kind, id DESC
+			LIMIT $2 OFFSET $9
+		) found
+		ORDER BY rank DESC, created DESC, kind, id DESC
+	`
+
+	isAdminSQL = `
+		SELECT "is_admin"
+		FROM users
+		WHERE "nickname" = $1
+	`
+	setAdminSQL = `
+		UPDATE users
+		SET "is_admin" = $2
+		WHERE "nickname" = $1
+	`
+	isModeratorSQL = `
+		SELECT EXISTS (SELECT 1 FROM forum_moderators WHERE forum = $1 AND nickname = $2)
+			OR EXISTS (SELECT 1 FROM forums WHERE slug = $1 AND "user" = $2)
+	`
+	grantModeratorSQL = `
+		INSERT INTO forum_moderators ("forum", "nickname")
+		SELECT f.slug, u.nickname
+		FROM forums f, users u
+		WHERE f.slug = $1 AND u.nickname = $2
+		ON CONFLICT DO NOTHING
+	`
+	revokeModeratorSQL = `
+		DELETE FROM forum_moderators
+		WHERE forum = $1 AND nickname = $2
+	`
+	getModeratorsSQL = `
+		SELECT u.nickname, u.fullname, u.email, u.about, u.karma
+		FROM forum_moderators m
+		JOIN users u ON u.nickname = m.nickname
+		WHERE m.forum = $1
+		ORDER BY u.nickname COLLATE ucs_basic
+	`
 )
@@ -1,25 +1,38 @@
 package repository
 
 import (
-	"fmt"
+	"context"
+	"github.com/AntonPriyma/db_forum/metrics"
 	"github.com/AntonPriyma/db_forum/models"
+	"github.com/AntonPriyma/db_forum/utils"
 	"github.com/jackc/pgx"
 	"strconv"
+	"time"
 )
 
 type ThreadDBRepository interface {
-	Create(thread *models.Thread) (*models.Thread,error) // ok
-	UpdateThreadDB(thread *models.ThreadUpdate, param string) (*models.Thread, error) //ok
-	MakeThreadVoteDB(vote *models.Vote, param string) (*models.Thread, error) //ok
-	GetThreadsByForum(slug, limit, since, desc string) (*models.Threads, error) //ok
-	GetThread(param string) (*models.Thread, error) //ok
-	parentExitsInOtherThread(parent int64, threadID int32) bool
-	parentNotExists(parent int64) bool
+	Create(ctx context.Context, thread *models.Thread) (*models.Thread,error) // ok
+	UpdateThreadDB(ctx context.Context, thread *models.ThreadUpdate, param string) (*models.Thread, error) //ok
+	MakeThreadVoteDB(ctx context.Context, vote *models.Vote, param string) (*models.Thread, error) //ok
+	// RetractThreadVote убирает голос nickname и откатывает его в threads.votes
+	RetractThreadVote(ctx context.Context, nickname, param string) (*models.Thread, error)
+	// GetThreadsByForum скрытые модераторами треды отдаются только при showDeleted = "true"
+	GetThreadsByForum(ctx context.Context, slug, limit, since, desc, showDeleted string) (*models.Threads, error) //ok
+	GetThread(ctx context.Context, param string) (*models.Thread, error) //ok
+	// GetUserThreads треды автора по (created, id), since - id последнего треда прошлой страницы, forum необязателен
+	GetUserThreads(ctx context.Context, nickname, limit, since, desc, forum string) (*models.Threads, error)
+	Delete(ctx context.Context, param string) (*models.Thread, error)
+	// Hide скрывает тред от имени модератора nickname, Restore возвращает его обратно
+	Hide(ctx context.Context, param, nickname string) (*models.Thread, error)
+	Restore(ctx context.Context, param string) (*models.Thread, error)
+	parentExitsInOtherThread(ctx context.Context, parent int64, threadID int32) bool
+	parentNotExists(ctx context.Context, parent int64) bool
 }
 
-func(p *ThreadDBRepositoryImpl) parentExitsInOtherThread(parent int64, threadID int32) bool {
+func(p *ThreadDBRepositoryImpl) parentExitsInOtherThread(ctx context.Context, parent int64, threadID int32) bool {
+	defer metrics.ObserveQuery("threads", "parentExitsInOtherThread", "", time.Now())
 	var t int64
-	err := p.db.QueryRow(postID, parent, threadID).Scan(&t)
+	err := p.db.QueryRowEx(ctx, postID, nil, parent, threadID).Scan(&t)
 
 	if err != nil && err.Error() == models.NoRowsInResult {
 		return false
@@ -27,13 +40,14 @@ func(p *ThreadDBRepositoryImpl) parentExitsInOtherThread(parent int64, threadID
 	return true
 }
 
-func(p *ThreadDBRepositoryImpl) parentNotExists(parent int64) bool {
+func(p *ThreadDBRepositoryImpl) parentNotExists(ctx context.Context, parent int64) bool {
+	defer metrics.ObserveQuery("threads", "parentNotExists", "", time.Now())
 	if parent == 0 {
 		return false
 	}
 
 	var t int64
-	err := p.db.QueryRow(`SELECT id FROM posts WHERE id = $1`, parent).Scan(&t)
+	err := p.db.QueryRowEx(ctx, `SELECT id FROM posts WHERE id = $1`, nil, parent).Scan(&t)
 
 	if err != nil {
 		return true
@@ -54,10 +68,11 @@ type ThreadDBRepositoryImpl struct{
 	forums ForumRepository
 }
 
-func (t *ThreadDBRepositoryImpl) MakeThreadVoteDB(vote *models.Vote, param string) (*models.Thread, error) {
+func (t *ThreadDBRepositoryImpl) MakeThreadVoteDB(ctx context.Context, vote *models.Vote, param string) (*models.Thread, error) {
+	defer metrics.ObserveQuery("threads", "MakeThreadVoteDB", "", time.Now())
 	var err error
 
-	tx, txErr := t.db.Begin()
+	tx, txErr := t.db.BeginEx(ctx, nil)
 	if txErr != nil {
 		return nil, txErr
 	}
@@ -66,7 +81,7 @@ func (t *ThreadDBRepositoryImpl) MakeThreadVoteDB(vote *models.Vote, param strin
 	var thread models.Thread
 	if isNumber(param) {
 		id, _ := strconv.Atoi(param)
-		err = tx.QueryRow(`SELECT id, author, created, forum, message, slug, title, votes FROM threads WHERE id = $1`, id).Scan(
+		err = tx.QueryRowEx(ctx, `SELECT id, author, created, forum, message, slug, title, votes, deleted, coalesce(deleted_by, ''), deleted_at FROM threads WHERE id = $1`, nil, id).Scan(
 			&thread.ID,
 			&thread.Author,
 			&thread.Created,
@@ -75,9 +90,12 @@ func (t *ThreadDBRepositoryImpl) MakeThreadVoteDB(vote *models.Vote, param strin
 			&thread.Slug,
 			&thread.Title,
 			&thread.Votes,
+			&thread.Deleted,
+			&thread.DeletedBy,
+			&thread.DeletedAt,
 		)
 	} else {
-		err = tx.QueryRow(`SELECT id, author, created, forum, message, slug, title, votes FROM threads WHERE slug = $1`, param).Scan(
+		err = tx.QueryRowEx(ctx, `SELECT id, author, created, forum, message, slug, title, votes, deleted, coalesce(deleted_by, ''), deleted_at FROM threads WHERE slug = $1`, nil, param).Scan(
 			&thread.ID,
 			&thread.Author,
 			&thread.Created,
@@ -86,45 +104,108 @@ func (t *ThreadDBRepositoryImpl) MakeThreadVoteDB(vote *models.Vote, param strin
 			&thread.Slug,
 			&thread.Title,
 			&thread.Votes,
+			&thread.Deleted,
+			&thread.DeletedBy,
+			&thread.DeletedAt,
 		)
 	}
+	if err == pgx.ErrNoRows {
+		return nil, models.ThreadNotFound
+	}
 	if err != nil {
-		return nil, models.ForumNotFound
+		utils.Logger(ctx).WithError(err).WithField("thread", param).Error("vote thread query failed")
+		return nil, err
 	}
 
 	var nick string
-	err = tx.QueryRow(`SELECT nickname FROM users WHERE nickname = $1`, vote.Nickname).Scan(&nick)
-	if err != nil {
+	err = tx.QueryRowEx(ctx, `SELECT nickname FROM users WHERE nickname = $1`, nil, vote.Nickname).Scan(&nick)
+	if err == pgx.ErrNoRows {
 		return nil, models.UserNotFound
 	}
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := tx.Exec(`UPDATE votes SET voice = $1 WHERE thread = $2 AND nickname = $3;`, vote.Voice, thread.ID, vote.Nickname)
-	if rows.RowsAffected() == 0 {
-		_, err := tx.Exec(`INSERT INTO votes (nickname, thread, voice) VALUES ($1, $2, $3);`, vote.Nickname, thread.ID, vote.Voice)
-		if err != nil {
+	rows, err := tx.ExecEx(ctx, `UPDATE votes SET voice = $1 WHERE thread = $2 AND nickname = $3;`, nil, vote.Voice, thread.ID, vote.Nickname)
+	if err == nil && rows.RowsAffected() == 0 {
+		_, err = tx.ExecEx(ctx, `INSERT INTO votes (nickname, thread, voice) VALUES ($1, $2, $3);`, nil, vote.Nickname, thread.ID, vote.Voice)
+	}
+	if err != nil {
+		switch ErrorCode(err) {
+		case models.PgxErrForeignKey:
 			return nil, models.UserNotFound
+		case models.PgxErrCheck:
+			return nil, models.InvalidVoice
 		}
+		utils.Logger(ctx).WithError(err).WithField("thread", thread.ID).Error("vote insert failed")
+		return nil, err
 	}
-	// если возник вопрос - в какой мемент делаем +1 к voice -> смотри init.sql
+	// если возник вопрос - в какой мемент делаем +1 к voice -> смотри триггеры в migrations.go
 
-	err = tx.QueryRow(`SELECT votes FROM threads WHERE id = $1`, thread.ID).Scan(&thread.Votes)
+	err = tx.QueryRowEx(ctx, `SELECT votes FROM threads WHERE id = $1`, nil, thread.ID).Scan(&thread.Votes)
 	if err != nil {
 		return nil, err
 	}
 
-	tx.Commit()
+	if err = tx.Commit(); err != nil {
+		utils.Logger(ctx).WithError(err).WithField("thread", thread.ID).Error("vote commit failed")
+		return nil, err
+	}
 
+	voice := int32(vote.Voice)
+	thread.Voice = &voice
+	thread.HideDeleted()
 	return &thread, nil
 }
 
-func (t *ThreadDBRepositoryImpl) GetThread(param string) (*models.Thread, error) {
+func (t *ThreadDBRepositoryImpl) RetractThreadVote(ctx context.Context, nickname, param string) (*models.Thread, error) {
+	defer metrics.ObserveQuery("threads", "RetractThreadVote", "", time.Now())
+	thread, err := t.GetThread(ctx, param)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := t.db.BeginEx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tag, err := tx.ExecEx(ctx, deleteVoteSQL, nil, thread.ID, nickname)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("thread", thread.ID).Error("vote delete failed")
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		var nick string
+		if err = tx.QueryRowEx(ctx, `SELECT nickname FROM users WHERE nickname = $1`, nil, nickname).Scan(&nick); err != nil {
+			return nil, models.UserNotFound
+		}
+		return nil, models.VoteNotFound
+	}
+	// threads.votes поправил триггер delete_vote
+	if err = tx.QueryRowEx(ctx, `SELECT votes FROM threads WHERE id = $1`, nil, thread.ID).Scan(&thread.Votes); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	var voice int32
+	thread.Voice = &voice
+	return thread, nil
+}
+
+func (t *ThreadDBRepositoryImpl) GetThread(ctx context.Context, param string) (*models.Thread, error) {
+	defer metrics.ObserveQuery("threads", "GetThread", "", time.Now())
 	var err error
 	var thread models.Thread
 
 	if isNumber(param) {
 		id, _ := strconv.Atoi(param)
-		err = t.db.QueryRow(
-			getThreadIdSQL,
+		err = t.db.QueryRowEx(ctx,
+			getThreadIdSQL, nil,
 			id,
 		).Scan(
 			&thread.ID,
@@ -135,10 +216,13 @@ func (t *ThreadDBRepositoryImpl) GetThread(param string) (*models.Thread, error)
 			&thread.Votes,
 			&thread.Slug,
 			&thread.Created,
+			&thread.Deleted,
+			&thread.DeletedBy,
+			&thread.DeletedAt,
 		)
 	} else {
-		err = t.db.QueryRow(
-			getThreadSlugSQL,
+		err = t.db.QueryRowEx(ctx,
+			getThreadSlugSQL, nil,
 			param,
 		).Scan(
 			&thread.ID,
@@ -149,6 +233,9 @@ func (t *ThreadDBRepositoryImpl) GetThread(param string) (*models.Thread, error)
 			&thread.Votes,
 			&thread.Slug,
 			&thread.Created,
+			&thread.Deleted,
+			&thread.DeletedBy,
+			&thread.DeletedAt,
 		)
 	}
 
@@ -156,20 +243,22 @@ func (t *ThreadDBRepositoryImpl) GetThread(param string) (*models.Thread, error)
 		return nil, models.ThreadNotFound
 	}
 
+	thread.HideDeleted()
 	return &thread, nil
 }
 
-func (t *ThreadDBRepositoryImpl) Create(thread *models.Thread) (*models.Thread, error) {
+func (t *ThreadDBRepositoryImpl) Create(ctx context.Context, thread *models.Thread) (*models.Thread, error) {
+	defer metrics.ObserveQuery("threads", "Create", "", time.Now())
 	if thread.Slug != "" {
-		thread, err := t.GetThread(thread.Slug)
+		thread, err := t.GetThread(ctx, thread.Slug)
 		if err == nil {
 			return thread, models.ThreadIsExist
 		}
 	}
 
 
-	err := t.db.QueryRow(
-		createForumThreadSQL,
+	err := t.db.QueryRowEx(ctx,
+		createForumThreadSQL, nil,
 		&thread.Author,
 		&thread.Created,
 		&thread.Message,
@@ -185,8 +274,6 @@ func (t *ThreadDBRepositoryImpl) Create(thread *models.Thread) (*models.Thread,
 		&thread.Title,
 	)
 
-	fmt.Println(thread.ID, err)
-
 	switch ErrorCode(err) {
 	case models.PgxOK:
 		return thread, nil
@@ -194,23 +281,31 @@ func (t *ThreadDBRepositoryImpl) Create(thread *models.Thread) (*models.Thread,
 		return nil, models.ForumOrAuthorNotFound //UserNotFound
 	case models.PgxErrForeignKey:
 		return nil, models.ForumOrAuthorNotFound //ForumIsExist
+	case models.PgxErrForumArchived:
+		return nil, models.ForumArchived
 	default:
+		utils.Logger(ctx).WithError(err).WithField("forum", thread.Forum).Error("thread insert failed")
 		return nil, err
 	}
 }
 
-func (t *ThreadDBRepositoryImpl) UpdateThreadDB(thread *models.ThreadUpdate, param string) (*models.Thread, error) {
-	threadFound, err := t.GetThread(param)
+func (t *ThreadDBRepositoryImpl) UpdateThreadDB(ctx context.Context, thread *models.ThreadUpdate, param string) (*models.Thread, error) {
+	defer metrics.ObserveQuery("threads", "UpdateThreadDB", "", time.Now())
+	threadFound, err := t.GetThread(ctx, param)
 	if err != nil {
 		return nil, models.PostNotFound
 	}
+	if thread.IsEmpty() {
+		return threadFound, nil
+	}
 
 	updatedThread := models.Thread{}
 
-	err = t.db.QueryRow(updateThreadSQL,
-		&threadFound.Slug,
+	err = t.db.QueryRowEx(ctx, updateThreadSQL, nil,
+		threadFound.ID,
 		&thread.Title,
 		&thread.Message,
+		&thread.Slug,
 	).Scan(
 		&updatedThread.ID,
 		&updatedThread.Title,
@@ -220,26 +315,42 @@ func (t *ThreadDBRepositoryImpl) UpdateThreadDB(thread *models.ThreadUpdate, par
 		&updatedThread.Votes,
 		&updatedThread.Slug,
 		&updatedThread.Created,
+		&updatedThread.Deleted,
+		&updatedThread.DeletedBy,
+		&updatedThread.DeletedAt,
 	)
 
+	if err == pgx.ErrNoRows { // тред удалили между чтением и обновлением
+		return nil, models.PostNotFound
+	}
 	if err != nil {
+		if ErrorCode(err) == models.PgxErrUnique {
+			existing, err := t.GetThread(ctx, thread.Slug)
+			if err != nil {
+				return nil, err
+			}
+			return existing, models.ThreadIsExist
+		}
+		utils.Logger(ctx).WithError(err).WithField("thread", threadFound.ID).Error("thread update failed")
 		return nil, err
 	}
+	updatedThread.HideDeleted()
 
 	return &updatedThread, nil
 }
 
 
-func (t *ThreadDBRepositoryImpl) GetThreadsByForum(slug, limit, since, desc string) (*models.Threads, error) {
+func (t *ThreadDBRepositoryImpl) GetThreadsByForum(ctx context.Context, slug, limit, since, desc, showDeleted string) (*models.Threads, error) {
+	defer metrics.ObserveQuery("threads", "GetThreadsByForum", sortVariant("", desc, since), time.Now())
 	var rows *pgx.Rows
 	var err error
 
 	if since != "" {
 		query := QueryForumWithSince[desc]
-		rows, err = t.db.Query(query, slug, since, limit)
+		rows, err = t.db.QueryEx(ctx, query, nil, slug, since, limit, showDeleted)
 	} else {
 		query := QueryForumNoSince[desc]
-		rows, err = t.db.Query(query, slug, limit)
+		rows, err = t.db.QueryEx(ctx, query, nil, slug, limit, showDeleted)
 	}
 	defer rows.Close()
 
@@ -259,12 +370,16 @@ func (t *ThreadDBRepositoryImpl) GetThreadsByForum(slug, limit, since, desc stri
 			&t.Slug,
 			&t.Title,
 			&t.Votes,
+			&t.Deleted,
+			&t.DeletedBy,
+			&t.DeletedAt,
 		)
+		t.HideDeleted()
 		threads = append(threads, &t)
 	}
 
 	if len(threads) == 0 {
-		_, err := t.forums.GetForumBySlug(slug)
+		_, err := t.forums.GetForumBySlug(ctx, slug)
 		if err != nil {
 			return nil, models.ForumNotFound
 		}
@@ -272,11 +387,12 @@ func (t *ThreadDBRepositoryImpl) GetThreadsByForum(slug, limit, since, desc stri
 	return &threads, nil
 }
 
-func (t *ThreadDBRepositoryImpl) GetThreadByID(id int64) (*models.Thread, *models.Error) {
+func (t *ThreadDBRepositoryImpl) GetThreadByID(ctx context.Context, id int64) (*models.Thread, *models.Error) {
+	defer metrics.ObserveQuery("threads", "GetThreadByID", "", time.Now())
 	thread := &models.Thread{}
 	// спринтф затратно, потом надо это ускорить
-	row := t.db.QueryRow(`SELECT thread.id, thread.slug, thread.title, thread.message, thread.votes, thread.created,
-									thread.author, thread.forum FROM threads thread WHERE id = $1`, id)
+	row := t.db.QueryRowEx(ctx, `SELECT thread.id, thread.slug, thread.title, thread.message, thread.votes, thread.created,
+									thread.author, thread.forum FROM threads thread WHERE id = $1`, nil, id)
 	if err := row.Scan(&thread.ID, &thread.Slug,
 		&thread.Title, &thread.Message, &thread.Votes,
 		&thread.Created, &thread.Author, &thread.Forum); err != nil {
@@ -302,3 +418,150 @@ func NewThreadDBRepositoryImpl(db *pgx.ConnPool, forums ForumRepository) ThreadD
 	return &ThreadDBRepositoryImpl{db: db,forums:forums}
 }
 
+// Delete удаляет тред вместе с постами и голосами и уменьшает счётчики форума
+func (t *ThreadDBRepositoryImpl) Delete(ctx context.Context, param string) (*models.Thread, error) {
+	defer metrics.ObserveQuery("threads", "Delete", "", time.Now())
+	thread, err := t.GetThread(ctx, param)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := t.db.BeginEx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecEx(ctx, deleteThreadVotesSQL, nil, thread.ID); err != nil {
+		return nil, err
+	}
+	tag, err := tx.ExecEx(ctx, deleteThreadPostsSQL, nil, thread.ID)
+	if err != nil {
+		return nil, err
+	}
+	posts := tag.RowsAffected()
+
+	tag, err = tx.ExecEx(ctx, deleteThreadSQL, nil, thread.ID)
+	if err != nil {
+		return nil, err
+	}
+	// тред успели удалить параллельным запросом
+	if tag.RowsAffected() == 0 {
+		return nil, models.ThreadNotFound
+	}
+
+	if _, err = tx.ExecEx(ctx, updateForumCountersSQL, nil, -1, -posts, thread.Forum); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		utils.Logger(ctx).WithError(err).WithField("thread", thread.ID).Error("thread delete failed")
+		return nil, err
+	}
+
+	return thread, nil
+}
+
+func (t *ThreadDBRepositoryImpl) Hide(ctx context.Context, param, nickname string) (*models.Thread, error) {
+	defer metrics.ObserveQuery("threads", "Hide", "", time.Now())
+	thread, err := t.GetThread(ctx, param)
+	if err != nil {
+		return nil, err
+	}
+
+	var nick string
+	err = t.db.QueryRowEx(ctx, `SELECT nickname FROM users WHERE nickname = $1`, nil, nickname).Scan(&nick)
+	if err != nil {
+		return nil, models.UserNotFound
+	}
+
+	return t.setDeleted(ctx, hideThreadSQL, thread.ID, nick)
+}
+
+func (t *ThreadDBRepositoryImpl) Restore(ctx context.Context, param string) (*models.Thread, error) {
+	defer metrics.ObserveQuery("threads", "Restore", "", time.Now())
+	thread, err := t.GetThread(ctx, param)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.setDeleted(ctx, restoreThreadSQL, thread.ID)
+}
+
+// setDeleted выполняет hideThreadSQL или restoreThreadSQL и читает тред из RETURNING
+func (t *ThreadDBRepositoryImpl) setDeleted(ctx context.Context, query string, id int32, args ...interface{}) (*models.Thread, error) {
+	thread := models.Thread{}
+	err := t.db.QueryRowEx(ctx, query, nil, append([]interface{}{id}, args...)...).Scan(
+		&thread.ID,
+		&thread.Title,
+		&thread.Author,
+		&thread.Forum,
+		&thread.Message,
+		&thread.Votes,
+		&thread.Slug,
+		&thread.Created,
+		&thread.Deleted,
+		&thread.DeletedBy,
+		&thread.DeletedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, models.ThreadNotFound
+	}
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("thread", id).Error("thread visibility update failed")
+		return nil, err
+	}
+
+	thread.HideDeleted()
+	return &thread, nil
+}
+
+var queryUserThreads = map[string]string{
+	"true":  getUserThreadsDescSQL,
+	"false": getUserThreadsSQL,
+}
+
+func (t *ThreadDBRepositoryImpl) GetUserThreads(ctx context.Context, nickname, limit, since, desc, forum string) (*models.Threads, error) {
+	defer metrics.ObserveQuery("threads", "GetUserThreads", sortVariant("", desc, since), time.Now())
+	rows, err := t.db.QueryEx(ctx, queryUserThreads[desc], nil, nickname, limit, since, forum)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("nickname", nickname).Error("user threads query failed")
+		return nil, err
+	}
+	defer rows.Close()
+
+	threads := models.Threads{}
+	for rows.Next() {
+		thread := models.Thread{}
+		err = rows.Scan(
+			&thread.Author,
+			&thread.Created,
+			&thread.Forum,
+			&thread.ID,
+			&thread.Message,
+			&thread.Slug,
+			&thread.Title,
+			&thread.Votes,
+			&thread.Deleted,
+			&thread.DeletedBy,
+			&thread.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, &thread)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(threads) == 0 {
+		var nick string
+		err = t.db.QueryRowEx(ctx, `SELECT nickname FROM users WHERE nickname = $1`, nil, nickname).Scan(&nick)
+		if err != nil {
+			return nil, models.UserNotFound
+		}
+	}
+	return &threads, nil
+}
+
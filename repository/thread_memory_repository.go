@@ -0,0 +1,362 @@
+package repository
+
+import (
+	"context"
+	"github.com/AntonPriyma/db_forum/models"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type ThreadMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewThreadMemoryRepository(store *MemoryStore) ThreadDBRepository {
+	return &ThreadMemoryRepository{store: store}
+}
+
+func (t *ThreadMemoryRepository) Create(ctx context.Context, thread *models.Thread) (*models.Thread, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	if thread.Slug != "" {
+		if existing, ok := t.store.threadByParam(thread.Slug); ok {
+			copied := *existing
+			copied.HideDeleted()
+			return &copied, models.ThreadIsExist
+		}
+	}
+
+	forum, ok := t.store.forums[key(thread.Forum)]
+	if !ok {
+		return nil, models.ForumOrAuthorNotFound
+	}
+	// триггер forum_archived
+	if forum.Archived {
+		return nil, models.ForumArchived
+	}
+	if _, ok := t.store.users[key(thread.Author)]; !ok {
+		return nil, models.ForumOrAuthorNotFound
+	}
+
+	t.store.lastThreadID++
+	thread.ID = t.store.lastThreadID
+	thread.Forum = forum.Slug
+	created := *thread
+	t.store.threads[thread.ID] = &created
+	if thread.Slug != "" {
+		t.store.threadSlug[key(thread.Slug)] = thread.ID
+	}
+
+	// триггеры thread_insert и add_forum_user
+	t.store.addForumCounters(forum.Slug, 1, 0)
+	t.store.addForumUser(forum.Slug, thread.Author)
+
+	return thread, nil
+}
+
+func (t *ThreadMemoryRepository) GetThread(ctx context.Context, param string) (*models.Thread, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	existing, ok := t.store.threadByParam(param)
+	if !ok {
+		return nil, models.ThreadNotFound
+	}
+	thread := *existing
+	thread.HideDeleted()
+	return &thread, nil
+}
+
+func (t *ThreadMemoryRepository) UpdateThreadDB(ctx context.Context, thread *models.ThreadUpdate, param string) (*models.Thread, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	existing, ok := t.store.threadByParam(param)
+	if !ok {
+		return nil, models.PostNotFound
+	}
+	if thread.Slug != "" && key(thread.Slug) != key(existing.Slug) {
+		if id, ok := t.store.threadSlug[key(thread.Slug)]; ok {
+			conflict := *t.store.threads[id]
+			conflict.HideDeleted()
+			return &conflict, models.ThreadIsExist
+		}
+		delete(t.store.threadSlug, key(existing.Slug))
+		t.store.threadSlug[key(thread.Slug)] = existing.ID
+		existing.Slug = thread.Slug
+	}
+	if thread.Title != "" {
+		existing.Title = thread.Title
+	}
+	if thread.Message != "" {
+		existing.Message = thread.Message
+	}
+
+	updated := *existing
+	updated.HideDeleted()
+	return &updated, nil
+}
+
+func (t *ThreadMemoryRepository) MakeThreadVoteDB(ctx context.Context, vote *models.Vote, param string) (*models.Thread, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	thread, ok := t.store.threadByParam(param)
+	if !ok {
+		return nil, models.ThreadNotFound
+	}
+	if _, ok := t.store.users[key(vote.Nickname)]; !ok {
+		return nil, models.UserNotFound
+	}
+
+	votes, ok := t.store.votes[thread.ID]
+	if !ok {
+		votes = map[string]int32{}
+		t.store.votes[thread.ID] = votes
+	}
+	// триггеры insert_vote, update_vote и thread_vote_karma
+	delta := int32(vote.Voice) - votes[key(vote.Nickname)]
+	thread.Votes += delta
+	t.store.addKarma(thread.Author, delta)
+	votes[key(vote.Nickname)] = int32(vote.Voice)
+
+	result := *thread
+	voice := int32(vote.Voice)
+	result.Voice = &voice
+	result.HideDeleted()
+	return &result, nil
+}
+
+func (t *ThreadMemoryRepository) RetractThreadVote(ctx context.Context, nickname, param string) (*models.Thread, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	thread, ok := t.store.threadByParam(param)
+	if !ok {
+		return nil, models.ThreadNotFound
+	}
+	if _, ok := t.store.users[key(nickname)]; !ok {
+		return nil, models.UserNotFound
+	}
+	voice, ok := t.store.votes[thread.ID][key(nickname)]
+	if !ok {
+		return nil, models.VoteNotFound
+	}
+	// триггеры delete_vote и thread_vote_karma
+	thread.Votes -= voice
+	t.store.addKarma(thread.Author, -voice)
+	delete(t.store.votes[thread.ID], key(nickname))
+
+	result := *thread
+	voice = 0
+	result.Voice = &voice
+	result.HideDeleted()
+	return &result, nil
+}
+
+func (t *ThreadMemoryRepository) GetThreadsByForum(ctx context.Context, slug, limit, since, desc, showDeleted string) (*models.Threads, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	n, err := parseLimit(limit)
+	if err != nil || (desc != "true" && desc != "false") {
+		return nil, models.ForumNotFound
+	}
+	withDeleted, err := strconv.ParseBool(showDeleted)
+	if err != nil {
+		return nil, models.ForumNotFound
+	}
+	var sinceTime time.Time
+	if since != "" {
+		sinceTime, err = time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			return nil, models.ForumNotFound
+		}
+	}
+
+	found := make([]*models.Thread, 0)
+	for _, thread := range t.store.threads {
+		if key(thread.Forum) != key(slug) || (thread.Deleted && !withDeleted) {
+			continue
+		}
+		if since != "" {
+			if desc == "true" && thread.Created.After(sinceTime) {
+				continue
+			}
+			if desc == "false" && thread.Created.Before(sinceTime) {
+				continue
+			}
+		}
+		found = append(found, thread)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if !a.Created.Equal(b.Created) {
+			if desc == "true" {
+				return a.Created.After(b.Created)
+			}
+			return a.Created.Before(b.Created)
+		}
+		return a.ID < b.ID
+	})
+	if len(found) > n {
+		found = found[:n]
+	}
+
+	threads := models.Threads{}
+	for _, thread := range found {
+		copied := *thread
+		copied.HideDeleted()
+		threads = append(threads, &copied)
+	}
+
+	if len(threads) == 0 {
+		if _, ok := t.store.forums[key(slug)]; !ok {
+			return nil, models.ForumNotFound
+		}
+	}
+	return &threads, nil
+}
+
+func (t *ThreadMemoryRepository) parentExitsInOtherThread(ctx context.Context, parent int64, threadID int32) bool {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	post, ok := t.store.posts[parent]
+	return ok && post.Thread != threadID
+}
+
+func (t *ThreadMemoryRepository) parentNotExists(ctx context.Context, parent int64) bool {
+	if parent == 0 {
+		return false
+	}
+
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	_, ok := t.store.posts[parent]
+	return !ok
+}
+
+func (t *ThreadMemoryRepository) Delete(ctx context.Context, param string) (*models.Thread, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	existing, ok := t.store.threadByParam(param)
+	if !ok {
+		return nil, models.ThreadNotFound
+	}
+
+	posts := t.store.threadPosts[existing.ID]
+	for _, post := range posts {
+		t.store.removePostVotes(post)
+		delete(t.store.posts, post.ID)
+	}
+	delete(t.store.threadPosts, existing.ID)
+	t.store.removeThreadVotes(existing)
+	delete(t.store.threads, existing.ID)
+	if existing.Slug != "" {
+		delete(t.store.threadSlug, key(existing.Slug))
+	}
+
+	t.store.addForumCounters(existing.Forum, -1, -int64(len(posts)))
+
+	thread := *existing
+	return &thread, nil
+}
+
+func (t *ThreadMemoryRepository) Hide(ctx context.Context, param, nickname string) (*models.Thread, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	existing, ok := t.store.threadByParam(param)
+	if !ok {
+		return nil, models.ThreadNotFound
+	}
+	user, ok := t.store.users[key(nickname)]
+	if !ok {
+		return nil, models.UserNotFound
+	}
+
+	// deleted_at с точностью TIMESTAMPTZ(3)
+	now := time.Now().Truncate(time.Millisecond)
+	existing.Deleted = true
+	existing.DeletedBy = user.Nickname
+	existing.DeletedAt = &now
+
+	thread := *existing
+	thread.HideDeleted()
+	return &thread, nil
+}
+
+func (t *ThreadMemoryRepository) Restore(ctx context.Context, param string) (*models.Thread, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	existing, ok := t.store.threadByParam(param)
+	if !ok {
+		return nil, models.ThreadNotFound
+	}
+	existing.Deleted = false
+	existing.DeletedBy = ""
+	existing.DeletedAt = nil
+
+	thread := *existing
+	return &thread, nil
+}
+
+func (t *ThreadMemoryRepository) GetUserThreads(ctx context.Context, nickname, limit, since, desc, forum string) (*models.Threads, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	if _, ok := t.store.users[key(nickname)]; !ok {
+		return nil, models.UserNotFound
+	}
+	n, err := parseLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	// курсор (created, id) треда since, неизвестный since листает с начала
+	var cursor *models.Thread
+	if since != "" {
+		id, err := strconv.Atoi(since)
+		if err != nil {
+			return nil, err
+		}
+		cursor = t.store.threads[int32(id)]
+	}
+	isDesc := desc == "true"
+	before := func(a, b *models.Thread) bool {
+		if !a.Created.Equal(b.Created) {
+			return a.Created.Before(b.Created)
+		}
+		return a.ID < b.ID
+	}
+
+	found := make([]*models.Thread, 0)
+	for _, thread := range t.store.threads {
+		if key(thread.Author) != key(nickname) || thread.Deleted || (forum != "" && key(thread.Forum) != key(forum)) {
+			continue
+		}
+		if cursor != nil && ((isDesc && !before(thread, cursor)) || (!isDesc && !before(cursor, thread))) {
+			continue
+		}
+		found = append(found, thread)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return before(found[i], found[j]) != isDesc
+	})
+	if len(found) > n {
+		found = found[:n]
+	}
+
+	threads := models.Threads{}
+	for _, thread := range found {
+		copied := *thread
+		threads = append(threads, &copied)
+	}
+	return &threads, nil
+}
+
@@ -1,15 +1,27 @@
 package repository
 
 import (
+	"context"
+	"strings"
+	"github.com/AntonPriyma/db_forum/metrics"
 	"github.com/AntonPriyma/db_forum/models"
+	"github.com/AntonPriyma/db_forum/utils"
 	"github.com/jackc/pgx"
+	"time"
 )
 
 type UsersRepository interface {
-	Create(user *models.User) (models.Users, error)
-	Save(user *models.User) error
-	GetUserByNickname(nickname string) (*models.User, error)
-	authorExists(nickname string) bool
+	// Create в user.Password ожидает уже bcrypt хэш, он хранится отдельно и в ответах не отдаётся
+	Create(ctx context.Context, user *models.User) (models.Users, error)
+	Save(ctx context.Context, user *models.User) error
+	GetUserByNickname(ctx context.Context, nickname string) (*models.User, error)
+	// GetUsers все пользователи по nickname в том же порядке, что и GetForumUsersDB
+	GetUsers(ctx context.Context, limit, since, desc string) (*models.Users, error)
+	// SearchUsers пользователи, у которых nickname, fullname или email начинается с prefix без учёта регистра
+	SearchUsers(ctx context.Context, prefix, limit string) (*models.Users, error)
+	// GetPasswordHash nickname в том регистре, как он сохранён, и хэш пароля, пустой если пароль не задан
+	GetPasswordHash(ctx context.Context, nickname string) (string, string, error)
+	authorExists(ctx context.Context, nickname string) bool
 }
 
 
@@ -23,21 +35,24 @@ func NewUsersRepositoryImpl(db *pgx.ConnPool, forums ForumRepository) UsersRepos
 	return &UsersRepositoryImpl{db: db, forums: forums}
 }
 
-func (u *UsersRepositoryImpl) Create(user *models.User) (models.Users, error) {
-	rows, err := u.db.Exec(
-		createUserSQL,
+func (u *UsersRepositoryImpl) Create(ctx context.Context, user *models.User) (models.Users, error) {
+	defer metrics.ObserveQuery("users", "Create", "", time.Now())
+	rows, err := u.db.ExecEx(ctx,
+		createUserSQL, nil,
 		&user.Nickname,
 		&user.Fullname,
 		&user.Email,
 		&user.About,
+		&user.Password,
 	)
 	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("nickname", user.Nickname).Error("user insert failed")
 		return nil, err
 	}
 
 	if rows.RowsAffected() == 0 { // пользователь уже есть
 		users := models.Users{}
-		queryRows, err := u.db.Query(getUserByNicknameOrEmailSQL, user.Nickname, user.Email)
+		queryRows, err := u.db.QueryEx(ctx, getUserByNicknameOrEmailSQL, nil, user.Nickname, user.Email)
 		defer queryRows.Close()
 
 		if err != nil {
@@ -46,7 +61,7 @@ func (u *UsersRepositoryImpl) Create(user *models.User) (models.Users, error) {
 
 		for queryRows.Next() {
 			user := models.User{}
-			queryRows.Scan(&user.Nickname, &user.Fullname, &user.Email, &user.About)
+			queryRows.Scan(&user.Nickname, &user.Fullname, &user.Email, &user.About, &user.Karma)
 			users = append(users, &user)
 		}
 		return users, models.UserIsExist
@@ -56,9 +71,10 @@ func (u *UsersRepositoryImpl) Create(user *models.User) (models.Users, error) {
 
 }
 
-func (u *UsersRepositoryImpl) Save(user *models.User) error {
-	err := u.db.QueryRow(
-		updateUserSQL,
+func (u *UsersRepositoryImpl) Save(ctx context.Context, user *models.User) error {
+	defer metrics.ObserveQuery("users", "Save", "", time.Now())
+	err := u.db.QueryRowEx(ctx,
+		updateUserSQL, nil,
 		&user.Nickname,
 		&user.Fullname,
 		&user.Email,
@@ -68,6 +84,7 @@ func (u *UsersRepositoryImpl) Save(user *models.User) error {
 		&user.Fullname,
 		&user.Email,
 		&user.About,
+		&user.Karma,
 	)
 
 	if err != nil {
@@ -80,14 +97,16 @@ func (u *UsersRepositoryImpl) Save(user *models.User) error {
 	return nil
 }
 
-func (u *UsersRepositoryImpl) GetUserByNickname(nickname string) (*models.User, error) {
+func (u *UsersRepositoryImpl) GetUserByNickname(ctx context.Context, nickname string) (*models.User, error) {
+	defer metrics.ObserveQuery("users", "GetUserByNickname", "", time.Now())
 	user := models.User{}
 
-	err := GetDB().QueryRow(getUserSQL, nickname).Scan(
+	err := GetDB().QueryRowEx(ctx, getUserSQL, nil, nickname).Scan(
 		&user.Nickname,
 		&user.Fullname,
 		&user.Email,
 		&user.About,
+		&user.Karma,
 	)
 
 	if err != nil {
@@ -97,10 +116,25 @@ func (u *UsersRepositoryImpl) GetUserByNickname(nickname string) (*models.User,
 	return &user, nil
 }
 
-func (u *UsersRepositoryImpl) authorExists(nickname string) bool {
+func (u *UsersRepositoryImpl) GetPasswordHash(ctx context.Context, nickname string) (string, string, error) {
+	defer metrics.ObserveQuery("users", "GetPasswordHash", "", time.Now())
+	var hash string
+	err := u.db.QueryRowEx(ctx, getUserPasswordSQL, nil, nickname).Scan(&nickname, &hash)
+	if err != nil {
+		if err != pgx.ErrNoRows {
+			utils.Logger(ctx).WithError(err).WithField("nickname", nickname).Error("password query failed")
+			return "", "", err
+		}
+		return "", "", models.UserNotFound
+	}
+	return nickname, hash, nil
+}
+
+func (u *UsersRepositoryImpl) authorExists(ctx context.Context, nickname string) bool {
+	defer metrics.ObserveQuery("users", "authorExists", "", time.Now())
 	var user models.User
-	err := u.db.QueryRow(
-		getUserByNickname,
+	err := u.db.QueryRowEx(ctx,
+		getUserByNickname, nil,
 		nickname,
 	).Scan(
 		&user.Nickname,
@@ -114,3 +148,70 @@ func (u *UsersRepositoryImpl) authorExists(nickname string) bool {
 	}
 	return false
 }
+
+var queryUsersWithSince = map[string]string{
+	"true":  getUsersDescSinceSQL,
+	"false": getUsersSinceSQL,
+}
+
+var queryUsersNoSince = map[string]string{
+	"true":  getUsersDescSQL,
+	"false": getUsersSQL,
+}
+
+func (u *UsersRepositoryImpl) GetUsers(ctx context.Context, limit, since, desc string) (*models.Users, error) {
+	defer metrics.ObserveQuery("users", "GetUsers", sortVariant("", desc, since), time.Now())
+	var rows *pgx.Rows
+	var err error
+
+	if since != "" {
+		rows, err = u.db.QueryEx(ctx, queryUsersWithSince[desc], nil, limit, since)
+	} else {
+		rows, err = u.db.QueryEx(ctx, queryUsersNoSince[desc], nil, limit)
+	}
+	if err != nil {
+		utils.Logger(ctx).WithError(err).Error("users query failed")
+		return nil, err
+	}
+	return scanUsers(rows)
+}
+
+// likePrefixEscaper экранирование спецсимволов LIKE, escape по умолчанию - обратный слэш
+var likePrefixEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (u *UsersRepositoryImpl) SearchUsers(ctx context.Context, prefix, limit string) (*models.Users, error) {
+	defer metrics.ObserveQuery("users", "SearchUsers", "", time.Now())
+	pattern := likePrefixEscaper.Replace(strings.ToLower(prefix)) + "%"
+
+	rows, err := u.db.QueryEx(ctx, searchUsersSQL, nil, pattern, limit)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).Error("users search failed")
+		return nil, err
+	}
+	return scanUsers(rows)
+}
+
+func scanUsers(rows *pgx.Rows) (*models.Users, error) {
+	defer rows.Close()
+
+	users := models.Users{}
+	for rows.Next() {
+		user := models.User{}
+		err := rows.Scan(
+			&user.Nickname,
+			&user.Fullname,
+			&user.Email,
+			&user.About,
+			&user.Karma,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &users, nil
+}
+
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"github.com/AntonPriyma/db_forum/models"
+	"sort"
+	"strings"
+)
+
+type UsersMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewUsersMemoryRepository(store *MemoryStore) UsersRepository {
+	return &UsersMemoryRepository{store: store}
+}
+
+func (u *UsersMemoryRepository) Create(ctx context.Context, user *models.User) (models.Users, error) {
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	conflicts := models.Users{}
+	for _, existing := range u.store.users {
+		if key(existing.Nickname) == key(user.Nickname) || key(existing.Email) == key(user.Email) {
+			copied := *existing
+			conflicts = append(conflicts, &copied)
+		}
+	}
+	if len(conflicts) != 0 {
+		return conflicts, models.UserIsExist
+	}
+
+	created := *user
+	created.Password = ""
+	u.store.users[key(user.Nickname)] = &created
+	if user.Password != "" {
+		u.store.passwords[key(user.Nickname)] = user.Password
+	}
+	return nil, nil
+}
+
+func (u *UsersMemoryRepository) Save(ctx context.Context, user *models.User) error {
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	existing, ok := u.store.users[key(user.Nickname)]
+	if !ok {
+		return models.UserNotFound
+	}
+
+	if user.Email != "" {
+		for _, other := range u.store.users {
+			if other != existing && key(other.Email) == key(user.Email) {
+				return models.UserUpdateConflict
+			}
+		}
+		existing.Email = user.Email
+	}
+	if user.Fullname != "" {
+		existing.Fullname = user.Fullname
+	}
+	if user.About != "" {
+		existing.About = user.About
+	}
+
+	*user = *existing
+	return nil
+}
+
+func (u *UsersMemoryRepository) GetUserByNickname(ctx context.Context, nickname string) (*models.User, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	existing, ok := u.store.users[key(nickname)]
+	if !ok {
+		return nil, models.UserNotFound
+	}
+	user := *existing
+	return &user, nil
+}
+
+func (u *UsersMemoryRepository) GetPasswordHash(ctx context.Context, nickname string) (string, string, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	existing, ok := u.store.users[key(nickname)]
+	if !ok {
+		return "", "", models.UserNotFound
+	}
+	return existing.Nickname, u.store.passwords[key(nickname)], nil
+}
+
+// authorExists как и в postgres реализации возвращает true, если автора НЕТ
+func (u *UsersMemoryRepository) authorExists(ctx context.Context, nickname string) bool {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	_, ok := u.store.users[key(nickname)]
+	return !ok
+}
+
+func (u *UsersMemoryRepository) GetUsers(ctx context.Context, limit, since, desc string) (*models.Users, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	n, err := parseLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// nickname COLLATE ucs_basic: побайтово по нижнему регистру
+	found := make([]*models.User, 0, len(u.store.users))
+	for _, user := range u.store.users {
+		if since != "" {
+			if desc == "true" && key(user.Nickname) >= key(since) {
+				continue
+			}
+			if desc != "true" && key(user.Nickname) <= key(since) {
+				continue
+			}
+		}
+		found = append(found, user)
+	}
+	return sortUsers(found, desc == "true", n), nil
+}
+
+func (u *UsersMemoryRepository) SearchUsers(ctx context.Context, prefix, limit string) (*models.Users, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	n, err := parseLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix = strings.ToLower(prefix)
+	found := make([]*models.User, 0)
+	for _, user := range u.store.users {
+		for _, field := range []string{user.Nickname, user.Fullname, user.Email} {
+			if strings.HasPrefix(strings.ToLower(field), prefix) {
+				found = append(found, user)
+				break
+			}
+		}
+	}
+	return sortUsers(found, false, n), nil
+}
+
+// sortUsers копии первых n пользователей в порядке nickname COLLATE ucs_basic
+func sortUsers(found []*models.User, desc bool, n int) *models.Users {
+	sort.Slice(found, func(i, j int) bool {
+		if desc {
+			return key(found[i].Nickname) > key(found[j].Nickname)
+		}
+		return key(found[i].Nickname) < key(found[j].Nickname)
+	})
+	if len(found) > n {
+		found = found[:n]
+	}
+
+	users := models.Users{}
+	for _, user := range found {
+		copied := *user
+		users = append(users, &copied)
+	}
+	return &users
+}
+
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ошибки разбора токена
+var (
+	ErrTokenInvalid = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// Tokens выдаёт и проверяет токены сессий вида base64(nickname).expires.base64(hmac-sha256)
+type Tokens struct {
+	secret []byte
+}
+
+func NewTokens(secret []byte) *Tokens {
+	return &Tokens{secret: secret}
+}
+
+// Sign токен для nickname, действующий до expires
+func (t *Tokens) Sign(nickname string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(nickname)) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(t.mac(payload))
+}
+
+// Parse проверяет подпись и срок действия токена, возвращает nickname
+func (t *Tokens) Parse(token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrTokenInvalid
+	}
+	payload := parts[0] + "." + parts[1]
+	sign, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sign, t.mac(payload)) {
+		return "", ErrTokenInvalid
+	}
+	nickname, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrTokenInvalid
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", ErrTokenInvalid
+	}
+	if now.Unix() >= expires {
+		return "", ErrTokenExpired
+	}
+	return string(nickname), nil
+}
+
+func (t *Tokens) mac(payload string) []byte {
+	h := hmac.New(sha256.New, t.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+type callerKey struct{}
+
+// WithCaller кладёт в контекст nickname пользователя, от имени которого идёт запрос
+func WithCaller(ctx context.Context, nickname string) context.Context {
+	return context.WithValue(ctx, callerKey{}, nickname)
+}
+
+// Caller nickname из токена запроса, ok false для анонимного запроса
+func Caller(ctx context.Context) (nickname string, ok bool) {
+	nickname, ok = ctx.Value(callerKey{}).(string)
+	return nickname, ok
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	tokens := NewTokens([]byte("secret"))
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	token := tokens.Sign("some.user", now.Add(time.Hour))
+
+	nickname, err := tokens.Parse(token, now)
+	if err != nil || nickname != "some.user" {
+		t.Fatalf("Parse = %q, %v", nickname, err)
+	}
+
+	if _, err = tokens.Parse(token, now.Add(time.Hour)); err != ErrTokenExpired {
+		t.Errorf("expired token err = %v", err)
+	}
+	if _, err = NewTokens([]byte("other")).Parse(token, now); err != ErrTokenInvalid {
+		t.Errorf("foreign secret err = %v", err)
+	}
+	forged := tokens.Sign("admin", now.Add(time.Hour))
+	if _, err = tokens.Parse(forged[:len("YWRtaW4")]+token[len("c29tZS51c2Vy"):], now); err != ErrTokenInvalid {
+		t.Errorf("swapped nickname err = %v", err)
+	}
+	for _, bad := range []string{"", "a.b", "a.b.c.d", token + "x"} {
+		if _, err = tokens.Parse(bad, now); err != ErrTokenInvalid {
+			t.Errorf("Parse(%q) err = %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"unicode"
+
+	"github.com/AntonPriyma/db_forum/models"
+)
+
+// maxDiffCells ограничение на размер таблицы LCS, дальше отдаём замену целиком
+const maxDiffCells = 4 << 20
+
+// DiffWords пословная разница двух текстов.
+// Пробельные куски идут отдельными токенами, поэтому склейка equal и delete даёт from,
+// а склейка equal и insert даёт to.
+func DiffWords(from, to string) []models.DiffChunk {
+	a, b := splitWords(from), splitWords(to)
+	if len(a)*len(b) > maxDiffCells {
+		return mergeChunks([]models.DiffChunk{
+			{Op: models.DiffDelete, Text: from},
+			{Op: models.DiffInsert, Text: to},
+		})
+	}
+
+	// lcs[i][j] длина общей подпоследовательности a[i:] и b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	chunks := make([]models.DiffChunk, 0)
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			chunks = append(chunks, models.DiffChunk{Op: models.DiffEqual, Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			chunks = append(chunks, models.DiffChunk{Op: models.DiffDelete, Text: a[i]})
+			i++
+		default:
+			chunks = append(chunks, models.DiffChunk{Op: models.DiffInsert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		chunks = append(chunks, models.DiffChunk{Op: models.DiffDelete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		chunks = append(chunks, models.DiffChunk{Op: models.DiffInsert, Text: b[j]})
+	}
+
+	return mergeChunks(chunks)
+}
+
+// splitWords режет текст на чередующиеся пробельные и непробельные куски
+func splitWords(s string) []string {
+	words := make([]string, 0)
+	start := 0
+	prevSpace := false
+	for i, r := range s {
+		space := unicode.IsSpace(r)
+		if i > 0 && space != prevSpace {
+			words = append(words, s[start:i])
+			start = i
+		}
+		prevSpace = space
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// mergeChunks склеивает соседние куски с одной операцией и выкидывает пустые
+func mergeChunks(chunks []models.DiffChunk) []models.DiffChunk {
+	merged := make([]models.DiffChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		if chunk.Text == "" {
+			continue
+		}
+		if n := len(merged); n > 0 && merged[n-1].Op == chunk.Op {
+			merged[n-1].Text += chunk.Text
+			continue
+		}
+		merged = append(merged, chunk)
+	}
+	return merged
+}
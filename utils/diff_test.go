@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/AntonPriyma/db_forum/models"
+)
+
+func TestDiffWords(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     []models.DiffChunk
+	}{
+		{"same text", "same text", []models.DiffChunk{{Op: models.DiffEqual, Text: "same text"}}},
+		{"", "new", []models.DiffChunk{{Op: models.DiffInsert, Text: "new"}}},
+		{"old", "", []models.DiffChunk{{Op: models.DiffDelete, Text: "old"}}},
+		{
+			"the quick brown fox", "the slow brown fox",
+			[]models.DiffChunk{
+				{Op: models.DiffEqual, Text: "the "},
+				{Op: models.DiffDelete, Text: "quick"},
+				{Op: models.DiffInsert, Text: "slow"},
+				{Op: models.DiffEqual, Text: " brown fox"},
+			},
+		},
+		{
+			"привет мир", "привет,  дивный мир",
+			[]models.DiffChunk{
+				{Op: models.DiffDelete, Text: "привет"},
+				{Op: models.DiffInsert, Text: "привет,  дивный"},
+				{Op: models.DiffEqual, Text: " мир"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		got := DiffWords(c.from, c.to)
+		if len(got) != len(c.want) {
+			t.Errorf("DiffWords(%q, %q) = %+v, want %+v", c.from, c.to, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("DiffWords(%q, %q) = %+v, want %+v", c.from, c.to, got, c.want)
+				break
+			}
+		}
+
+		var from, to string
+		for _, chunk := range got {
+			if chunk.Op != models.DiffInsert {
+				from += chunk.Text
+			}
+			if chunk.Op != models.DiffDelete {
+				to += chunk.Text
+			}
+		}
+		if from != c.from || to != c.to {
+			t.Errorf("DiffWords(%q, %q) does not restore texts: %q, %q", c.from, c.to, from, to)
+		}
+	}
+}
@@ -71,6 +71,14 @@ func MakeErrorForum(s string) string {
 	return fmt.Sprintf(`{"message": "Can't find forum with slug: %s"}`, s)
 }
 
+func MakeErrorForumChildren(s string) string {
+	return fmt.Sprintf(`{"message": "Forum has sub-forums: %s"}`, s)
+}
+
+func MakeErrorArchived(s string) string {
+	return fmt.Sprintf(`{"message": %q}`, "Forum is archived, can't write to: "+s)
+}
+
 func MakeErrorThread(s string) string {
 	return fmt.Sprintf(`{"message": "Can't find thread by slug: %s"}`, s)
 }
@@ -91,4 +99,31 @@ func MakeErrorPostAuthor(s string) string {
 	return fmt.Sprintf(`{"message": "Can't find post author by nickname: %s"}`, s)
 }
 
+func MakeErrorRevision(post string, revision int32) string {
+	return fmt.Sprintf(`{"message": "Can't find revision %d of post with id: %s"}`, revision, post)
+}
+
+func MakeErrorBadParam(name, value string) string {
+	// значение пришло от клиента, %q экранирует кавычки
+	return fmt.Sprintf(`{"message": %q}`, "Invalid value of "+name+": "+value)
+}
+
+func MakeErrorUnauthorized(reason string) string {
+	return fmt.Sprintf(`{"message": %q}`, "Unauthorized: "+reason)
+}
+
+func MakeErrorForbidden(nickname string) string {
+	return fmt.Sprintf(`{"message": %q}`, "Can't act on behalf of user: "+nickname)
+}
+
+func MakeErrorAccess(nickname string) string {
+	return fmt.Sprintf(`{"message": %q}`, "Not enough rights for user: "+nickname)
+}
+
+func MakeErrorModerator(forum, nickname string) string {
+	return fmt.Sprintf(`{"message": %q}`, "User "+nickname+" is not a moderator of forum: "+forum)
+}
 
+func MakeErrorVote(thread, nickname string) string {
+	return fmt.Sprintf(`{"message": %q}`, "User "+nickname+" has no vote in thread: "+thread)
+}